@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+type Achievement struct {
+	id   string
+	name string
+	desc string
+
+	// done reports whether the game so far has earned it
+	done func(g *Game) bool
+}
+
+// Tally counts what has happened in the game being played
+type Tally struct {
+	frames        int
+	shots         int
+	hits          int
+	kills         map[rune]int
+	ufoHits       int
+	deaths        map[string]int
+	waves         int
+	flawlessWaves int
+	intactWaves   int
+
+	// for the wave being played
+	waveHits       int
+	waveBarricades int
+}
+
+const (
+	achievementsFilename = "achievements"
+	achievementsTitle    = "ACHIEVEMENTS"
+	fgAchievements       = neonGreen
+	bgAchievements       = termbox.ColorBlack
+	fgLocked             = grey
+	achievementsWPad     = 6
+	achievementNamePad   = 16
+	achievementDescPad   = 42
+	achievementDateFmt   = "2006-01-02"
+
+	fgToast   = termbox.ColorBlack
+	bgToast   = yellow
+	toastText = "ACHIEVEMENT UNLOCKED"
+	toastTime = 3 * fps
+	toasty    = 4
+)
+
+var achievements = []*Achievement{
+	&Achievement{"first-kill", "FIRST CONTACT", "Destroy an alien", func(g *Game) bool {
+		return tally.killed() > 0
+	}},
+	&Achievement{"flawless", "FLAWLESS", "Clear a wave without losing a life", func(g *Game) bool {
+		return tally.flawlessWaves > 0
+	}},
+	&Achievement{"barricades", "UNBROKEN", "Clear a wave with every barricade intact", func(g *Game) bool {
+		return tally.intactWaves > 0
+	}},
+	&Achievement{"ufo-5", "SAUCER HUNTER", "Hit the UFO five times in one game", func(g *Game) bool {
+		return tally.ufoHits >= 5
+	}},
+	&Achievement{"armoured", "CAN OPENER", "Destroy an armoured alien", func(g *Game) bool {
+		return tally.kills['A'] > 0
+	}},
+	&Achievement{"level-10", "VETERAN", "Reach level 10", func(g *Game) bool {
+		return lvl >= 10
+	}},
+	&Achievement{"score-5000", "HIGH ROLLER", "Score 5000 points in one game", func(g *Game) bool {
+		return player.score >= 5000
+	}},
+	&Achievement{"hardcore", "IRON NERVE", "Clear a wave in hardcore mode", func(g *Game) bool {
+		return g.mode == HardcoreMode && tally.waves > 0
+	}},
+}
+
+var (
+	tally Tally
+
+	// unlocked achievements waiting to be shown, and how long the first
+	// one has been up
+	toasts    []*Achievement
+	toastLife int
+)
+
+func newTally() Tally {
+	return Tally{kills: make(map[rune]int), deaths: make(map[string]int)}
+}
+
+func (t *Tally) killed() int {
+	n := 0
+	for _, k := range t.kills {
+		n += k
+	}
+	return n
+}
+
+// lostLife records the player being hit or the aliens landing
+func (t *Tally) lostLife(cause string) {
+	t.waveHits++
+	t.deaths[cause]++
+}
+
+func (t *Tally) startWave() {
+	t.waveHits = 0
+	t.waveBarricades = barricadeCells()
+}
+
+func (t *Tally) waveCleared() {
+	t.waves++
+	if t.waveHits == 0 {
+		t.flawlessWaves++
+	}
+	if t.waveBarricades > 0 && barricadeCells() == t.waveBarricades {
+		t.intactWaves++
+	}
+}
+
+func barricadeCells() int {
+	n := 0
+	for i := range barricadePositions {
+		for _, b := range barricadePositions[i] {
+			if b != nonIndex {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// checkAchievements unlocks anything the game has just earned
+func (g *Game) checkAchievements() {
+	if !g.onRecord() {
+		return
+	}
+
+	unlocked := false
+	for _, a := range achievements {
+		if _, ok := g.achievements[a.id]; !ok && a.done(g) {
+			g.achievements[a.id] = time.Now()
+			toasts = append(toasts, a)
+			unlocked = true
+		}
+	}
+	if unlocked {
+		g.saveAchievements()
+	}
+}
+
+func (g *Game) loadAchievements() {
+	data, err := ioutil.ReadFile(dataPath(achievementsFilename))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return
+	}
+
+	for _, l := range strings.Split(string(data), "\n") {
+		if l == "" {
+			continue
+		}
+		parts := strings.Split(l, highscoreSeparator)
+		if len(parts) != 2 {
+			log.Println("achievements file has been corrupted - please correct/delete it")
+			continue
+		}
+		t, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			log.Println("achievements file has a bad date - please correct/delete it")
+			continue
+		}
+		g.achievements[parts[0]] = time.Unix(t, 0)
+	}
+}
+
+func (g *Game) saveAchievements() {
+	ids := make([]string, 0, len(g.achievements))
+	for id := range g.achievements {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	data := ""
+	for _, id := range ids {
+		data += fmt.Sprintf("%s%s%d\n", id, highscoreSeparator, g.achievements[id].Unix())
+	}
+	if err := writeFileAtomic(dataPath(achievementsFilename), []byte(data), 0666); err != nil {
+		log.Println(err)
+	}
+}
+
+func updateToasts() {
+	if len(toasts) == 0 {
+		return
+	}
+	toastLife++
+	if toastLife > toastTime {
+		toasts = toasts[1:]
+		toastLife = 0
+	}
+}
+
+func (g *Game) drawToast() {
+	if len(toasts) == 0 {
+		return
+	}
+	a := toasts[0]
+	name := a.name + " - " + a.desc
+	w := len(name)
+	if len(toastText) > w {
+		w = len(toastText)
+	}
+	w += 4
+
+	x := g.w/2 - w/2
+	tbprint(x, toasty, fgToast, bgToast, strings.Repeat(" ", w))
+	tbprint(g.w/2-len(toastText)/2, toasty, fgToast, bgToast, toastText)
+	tbprint(x, toasty+1, fgToast, bgToast, strings.Repeat(" ", w))
+	tbprint(g.w/2-len(name)/2, toasty+1, fgToast, bgToast, name)
+}
+
+func (g *Game) DrawAchievements() {
+	g.DrawMenu()
+
+	w := 4 + achievementNamePad + achievementDescPad + len(achievementDateFmt) + 2*achievementsWPad
+	h := len(achievements) + 7
+	x, y := g.w/2-w/2, logoY
+	tbrect(x, y, w, h, fgAchievements, bgAchievements, true)
+
+	y++
+	tbprint(g.w/2-len(achievementsTitle)/2, y, fgAchievements, bgAchievements, achievementsTitle)
+	y++
+	count := fmt.Sprintf("%d of %d unlocked", len(g.unlocked()), len(achievements))
+	tbprint(g.w/2-len(count)/2, y, magenta, bgAchievements, count)
+	y += 2
+
+	x += achievementsWPad
+	for _, a := range achievements {
+		if t, ok := g.achievements[a.id]; ok {
+			tbprint(x, y, fgAchievements, bgAchievements, fmt.Sprintf("[*] %-*s%-*s%s",
+				achievementNamePad, a.name, achievementDescPad, a.desc, t.Format(achievementDateFmt)))
+		} else {
+			tbprint(x, y, fgLocked, bgAchievements, fmt.Sprintf("[ ] %-*s%s", achievementNamePad, a.name, a.desc))
+		}
+		y++
+	}
+
+	y++
+	tbprintPrompt(y, "Press ", "ESC ", "to exit")
+}
+
+// unlocked returns the achievements that have been earned
+func (g *Game) unlocked() []*Achievement {
+	as := make([]*Achievement, 0)
+	for _, a := range achievements {
+		if _, ok := g.achievements[a.id]; ok {
+			as = append(as, a)
+		}
+	}
+	return as
+}
+
+func (g *Game) UpdateAchievements() {
+	g.UpdateMenu()
+}
+
+func (g *Game) HandleKeyAchievements(k termbox.Key) {
+	switch k {
+	case termbox.KeyEsc:
+		g.GoMenu()
+		g.hmi = Achievements
+	}
+}
+
+func (g *Game) GoAchievements() {
+	g.state = AchievementsState
+	g.cfg = fgMenu
+	g.cbg = bgMenu
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"math"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+type AlienKind struct {
+	// used in level packs and the editor
+	char   rune
+	name   string
+	blurb  string
+	sprite [2]string
+	fg     termbox.Attribute
+	reward int
+	hp     int
+
+	// kind it breaks into when destroyed, 0 if it doesn't
+	splitsInto rune
+	// fires at the player rather than straight down
+	aims bool
+	// aliens next to it can't be hurt
+	shields bool
+}
+
+const (
+	// rwd is reward
+	rwdArmoured = 50
+	rwdSplitter = 40
+	rwdTiny     = 10
+	rwdSniper   = 40
+	rwdShielder = 40
+
+	fgAlienFlash    = yellow
+	alienFlashTime  = fps / 5
+	maxAimedBulletV = 1.5
+)
+
+var alienKinds = []*AlienKind{
+	&AlienKind{'S', "SQUID", "", smAlienSprite, fgAlien, rwdSm, 1, 0, false, false},
+	&AlienKind{'M', "CRAB", "", mdAlienSprite, fgAlien, rwdMd, 1, 0, false, false},
+	&AlienKind{'L', "OCTOPUS", "", lgAlienSprite, fgAlien, rwdLg, 1, 0, false, false},
+	&AlienKind{'A', "ARMOURED", "takes 3 hits", armouredAlienSprite, grey, rwdArmoured, 3, 0, false, false},
+	&AlienKind{'X', "SPLITTER", "splits in two", splitterAlienSprite, orange, rwdSplitter, 1, 'T', false, false},
+	&AlienKind{'T', "SPLITLING", "", tinyAlienSprite, orange, rwdTiny, 1, 0, false, false},
+	&AlienKind{'N', "SNIPER", "aims at you", sniperAlienSprite, red, rwdSniper, 1, 0, true, false},
+	&AlienKind{'H', "SHIELDER", "guards others", shielderAlienSprite, cyan, rwdShielder, 1, 0, false, true},
+}
+
+func alienKind(c rune) *AlienKind {
+	for _, k := range alienKinds {
+		if k.char == c {
+			return k
+		}
+	}
+	return nil
+}
+
+func alienKindChars() string {
+	chars := make([]string, len(alienKinds))
+	for i, k := range alienKinds {
+		chars[i] = string(k.char)
+	}
+	return strings.Join(chars, "/")
+}
+
+// mixFormation brings in the special aliens as the levels go by
+func mixFormation(formation []string) []string {
+	mixed := make([]string, len(formation))
+	for i, row := range formation {
+		r := []rune(row)
+		for j := range r {
+			switch {
+			case i == 0 && lvl >= 2 && j%3 == 1:
+				r[j] = 'A'
+			case i == 1 && lvl >= 3 && j%4 == 0:
+				r[j] = 'N'
+			case i == len(formation)-2 && lvl >= 4 && j%4 == 2:
+				r[j] = 'H'
+			case i == len(formation)-1 && lvl >= 5 && j%3 == 0:
+				r[j] = 'X'
+			}
+		}
+		mixed[i] = string(r)
+	}
+	return mixed
+}
+
+// shielded reports whether the alien at i is next to a shielder in the
+// formation
+func shielded(i int) bool {
+	a := aliens[i]
+	if a.dive != nil || a.kind.shields || i >= aliensHorizontal*numRows {
+		return false
+	}
+
+	col := i % aliensHorizontal
+	for _, j := range []int{i - aliensHorizontal, i + aliensHorizontal, i - 1, i + 1} {
+		if j < 0 || j >= aliensHorizontal*numRows || (j == i-1 && col == 0) ||
+			(j == i+1 && col == aliensHorizontal-1) {
+			continue
+		}
+		if n := aliens[j]; n != nil && n.kind.shields && n.dive == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hitAlien is called when the player's bullet hits the alien at i
+func (g *Game) hitAlien(i, x, y int) {
+	a := aliens[i]
+	if shielded(i) {
+		a.flash = alienFlashTime
+		return
+	}
+
+	a.hp--
+	if a.hp > 0 {
+		a.flash = alienFlashTime
+		return
+	}
+
+	g.explode(x, y)
+	player.score += a.reward
+	aliens[i] = nil
+	tally.kills[a.kind.char]++
+
+	if k := alienKind(a.kind.splitsInto); k != nil {
+		for _, dx := range []int{-alienSpriteWidth / 4, alienSpriteWidth / 4} {
+			// keep them on the screen, or the formation would think it
+			// was at the right hand edge when it reached the left
+			hx := clamp(a.home.x+dx, 0, g.w-alienSpriteWidth)
+			s := NewAlien(hx, a.home.y, k)
+			s.x, s.y = clamp(a.x+dx, 0, g.w-alienSpriteWidth), a.y
+			if a.dive != nil {
+				s.dive = &Dive{returning: true}
+			}
+			aliens = append(aliens, s)
+		}
+	}
+}
+
+// alienFire makes a shoot, if there is a bullet free
+func (g *Game) alienFire(a *Alien) {
+	if g.practice && practice.holdFire {
+		return
+	}
+	for j := range alienBullets {
+		if alienBullets[j] == nil {
+			b := NewBullet(a.x+alienSpriteWidth/2, a.y, alienBulletSpeed)
+			if a.kind.aims {
+				dx := float64(player.x+player.ship.width/2) - b.fx
+				dy := float64(player.y - b.y)
+				if dy > 0 {
+					b.vx = math.Max(-maxAimedBulletV, math.Min(maxAimedBulletV, dx/dy*alienBulletSpeed))
+				}
+			}
+			alienBullets[j] = b
+			return
+		}
+	}
+}
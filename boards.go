@@ -0,0 +1,160 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// SizeClass is used as an enum. Bigger terminals fit more aliens, which
+// means more points, so their scores go on separate boards.
+type SizeClass uint8
+
+const (
+	SmallSize SizeClass = iota
+	MediumSize
+	LargeSize
+	NumSizeClasses
+)
+
+type SizeClassRules struct {
+	name string
+	key  string
+
+	// most aliens a formation can have in this class
+	maxAliens int
+}
+
+var sizeClasses = map[SizeClass]*SizeClassRules{
+	SmallSize:  &SizeClassRules{"SMALL", "small", 20},
+	MediumSize: &SizeClassRules{"MEDIUM", "medium", 35},
+	LargeSize:  &SizeClassRules{"LARGE", "large", 0},
+}
+
+func sizeByKey(key string) SizeClass {
+	for c, r := range sizeClasses {
+		if r.key == key {
+			return c
+		}
+	}
+	return NumSizeClasses
+}
+
+// sizeClass is the class of the terminal, going by how many aliens it fits
+func (g *Game) sizeClass() SizeClass {
+	cols, rows := g.formationSize()
+	for c := SmallSize; c < LargeSize; c++ {
+		if cols*rows <= sizeClasses[c].maxAliens {
+			return c
+		}
+	}
+	return LargeSize
+}
+
+// Board is one highscore table, kept here or, for the GLOBAL tab, on the
+// leaderboard server. Games with dive attacks, and games played with a
+// level pack, which is named by levels, aren't compared with normal ones.
+type Board struct {
+	mode       GameMode
+	difficulty Difficulty
+	size       SizeClass
+	dives      bool
+	levels     string
+	global     bool
+}
+
+func boardOf(h *Highscore) Board {
+	return Board{modeByKey(h.Mode), difficultyByKey(h.Difficulty), sizeByKey(h.Size), h.Dives, h.Levels, false}
+}
+
+// less orders boards the way the tabs go
+func (b Board) less(o Board) bool {
+	switch {
+	case b.mode != o.mode:
+		return b.mode < o.mode
+	case b.difficulty != o.difficulty:
+		return b.difficulty < o.difficulty
+	case b.levels != o.levels:
+		return b.levels < o.levels
+	case b.dives != o.dives:
+		return !b.dives
+	}
+	return b.size < o.size
+}
+
+func (b Board) String() string {
+	if b.global {
+		b.global = false
+		return globalTabLabel + b.String()
+	}
+	s := modes[b.mode].name + " / " + difficulties[b.difficulty].name + " / " + sizeClasses[b.size].name
+	if b.dives {
+		s += " / DIVES"
+	}
+	if b.levels != "" {
+		s += " / " + b.levels
+	}
+	return s
+}
+
+// currentBoard is the board a game with the chosen settings goes on
+func (g *Game) currentBoard() Board {
+	return Board{g.mode, g.difficulty, g.sizeClass(), g.dives, packName(), false}
+}
+
+// packName is what the level pack being played is called on its boards,
+// or "" if the levels are generated
+func packName() string {
+	if levelsFile == "" {
+		return ""
+	}
+	return filepath.Base(levelsFile)
+}
+
+// board returns the highscores on b, best first
+func (g *Game) board(b Board) []*Highscore {
+	scores := make([]*Highscore, 0)
+	if b.global {
+		if g.global != nil && g.global.board == b {
+			scores = append(scores, g.global.scores...)
+		}
+		sort.Stable(sort.Reverse(ByScore(scores)))
+		return scores
+	}
+	for _, s := range g.highscores {
+		if boardOf(s) == b {
+			scores = append(scores, s)
+		}
+	}
+	sort.Stable(sort.Reverse(ByScore(scores)))
+	return scores
+}
+
+// qualifies reports whether score makes it onto b. Nothing does without
+// scoring any points.
+func (g *Game) qualifies(b Board, score int) bool {
+	if score <= 0 {
+		return false
+	}
+	scores := g.board(b)
+	return len(scores) < maxHighscores || score > scores[maxHighscores-1].Score
+}
+
+// boardTabs are the boards that have highscores, along with the current
+// one, in order, then the GLOBAL tab for the current one if there's a
+// server and it isn't for a level pack, which the server doesn't take
+func (g *Game) boardTabs() []Board {
+	seen := map[Board]bool{g.currentBoard(): true}
+	tabs := []Board{g.currentBoard()}
+	for _, h := range g.highscores {
+		if b := boardOf(h); !seen[b] {
+			seen[b] = true
+			tabs = append(tabs, b)
+		}
+	}
+	sort.Slice(tabs, func(i, j int) bool { return tabs[i].less(tabs[j]) })
+	if b := g.currentBoard(); serverURL != "" && b.levels == "" {
+		b.global = true
+		tabs = append(tabs, b)
+	}
+	return tabs
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestQualifies(t *testing.T) {
+	g := NewGame()
+	b := Board{ClassicMode, Normal, MediumSize, false, "", false}
+	for _, c := range []struct {
+		name  string
+		score int
+		full  bool
+		want  bool
+	}{
+		{"nothing scored", 0, false, false},
+		{"empty board", 1, false, true},
+		{"full board, better", 11, true, true},
+		{"full board, same", 10, true, false},
+	} {
+		g.highscores = nil
+		if c.full {
+			for i := 0; i < maxHighscores; i++ {
+				g.highscores = append(g.highscores, &Highscore{Name: "ace", Score: 10, Mode: "classic",
+					Difficulty: "normal", Size: "medium"})
+			}
+		}
+		if got := g.qualifies(b, c.score); got != c.want {
+			t.Errorf("%s: qualifies is %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	clientTimeout  = 5 * time.Second
+	globalLoading  = "Fetching highscores from the server..."
+	globalTabLabel = "GLOBAL "
+)
+
+var (
+	// serverURL is the leaderboard server given with --server, if there is
+	// one
+	serverURL  string
+	httpClient = &http.Client{Timeout: clientTimeout}
+)
+
+// GlobalResult is what came back from fetching the GLOBAL tab
+type GlobalResult struct {
+	board  Board
+	scores []*Highscore
+	err    error
+}
+
+func scoresURL() string {
+	return strings.TrimRight(serverURL, "/") + scoresPath
+}
+
+// requestError leaves out the method and URL, which the player doesn't
+// need to see
+func requestError(err error) error {
+	if e, ok := err.(*url.Error); ok {
+		return e.Err
+	}
+	return err
+}
+
+// responseError turns an error response from the server into an error
+func responseError(resp *http.Response) error {
+	var e ErrorResponse
+	body, _ := ioutil.ReadAll(resp.Body)
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		return fmt.Errorf("server said: %s", e.Error)
+	}
+	return fmt.Errorf("server said: %s", resp.Status)
+}
+
+// submitHighscore sends a highscore, as JSON, to the server and returns its
+// rank there
+func submitHighscore(data []byte) (int, error) {
+	resp, err := httpClient.Post(scoresURL(), "application/json", bytes.NewReader(data))
+	if err != nil {
+		return 0, requestError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return 0, responseError(resp)
+	}
+
+	var s SubmitResponse
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return 0, err
+	}
+	return s.Rank, nil
+}
+
+// fetchHighscores gets board b from the server, leaving out anything that
+// doesn't make sense
+func fetchHighscores(b Board) ([]*Highscore, error) {
+	q := url.Values{}
+	q.Set("mode", modes[b.mode].key)
+	q.Set("difficulty", difficulties[b.difficulty].key)
+	q.Set("size", sizeClasses[b.size].key)
+	if b.dives {
+		q.Set("dives", "true")
+	}
+	resp, err := httpClient.Get(scoresURL() + "?" + q.Encode())
+	if err != nil {
+		return nil, requestError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var s ScoresResponse
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, err
+	}
+	scores := make([]*Highscore, 0, len(s.Scores))
+	for _, h := range s.Scores {
+		if h == nil {
+			continue
+		}
+		if _, ok := h.check(); ok {
+			scores = append(scores, h)
+		}
+	}
+	return scores, nil
+}
+
+// submitGlobal sends h to the server without holding up the game. Whether
+// it got there only goes in the log.
+func submitGlobal(h *Highscore) {
+	data, err := json.Marshal(h)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	go func() {
+		rank, err := submitHighscore(data)
+		if err != nil {
+			log.Printf("couldn't submit highscore to %s: %v", serverURL, err)
+			return
+		}
+		log.Printf("highscore is number %d on %s", rank, serverURL)
+	}()
+}
+
+// fetchGlobal starts getting the GLOBAL tab, which UpdateHighscores picks up
+func (g *Game) fetchGlobal(b Board) {
+	results := make(chan *GlobalResult, 1)
+	g.globalResults, g.global = results, nil
+	go func() {
+		scores, err := fetchHighscores(b)
+		results <- &GlobalResult{b, scores, err}
+	}()
+}
@@ -5,4 +5,8 @@ const (
 	red       = 0xc5
 	neonGreen = 0x53
 	magenta   = 0xc7
+	cyan      = 0x34
+	orange    = 0xd1
+	grey      = 0xf9
+	yellow    = 0xe3
 )
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+type Command struct {
+	usage string
+
+	// words that can follow the command, for tab completion
+	args []string
+
+	run func(g *Game, args []string) (string, error)
+}
+
+const (
+	consoleKey    = '`'
+	fgConsole     = neonGreen
+	bgConsole     = termbox.ColorBlack
+	fgConsoleOut  = white
+	consoleLines  = 8
+	consoleMargin = 4
+	consolePrompt = "> "
+	consoleHUD    = "CONSOLE USED  this game won't count"
+	maxLives      = 99
+	minSimSpeed   = 0.1
+	maxSimSpeed   = 4
+)
+
+var (
+	// commands is filled in by init, as some of the commands use it
+	commands map[string]*Command
+
+	conInput   string
+	conOutput  []string
+	conHistory []string
+
+	// position in conHistory when going back through it, len(conHistory)
+	// when not
+	conHistoryPos int
+)
+
+func init() {
+	commands = map[string]*Command{
+		"help": &Command{"help", nil, func(g *Game, args []string) (string, error) {
+			names := commandNames()
+			usages := make([]string, len(names))
+			for i, n := range names {
+				usages[i] = commands[n].usage
+			}
+			return strings.Join(usages, ", "), nil
+		}},
+		"level": &Command{"level N", nil, func(g *Game, args []string) (string, error) {
+			n, err := consoleInt(args, 1, maxPracticeLevel)
+			if err != nil {
+				return "", err
+			}
+			if n < lvl {
+				// go up again from the first level, as the escalation
+				// can't be undone a level at a time
+				lvl = 1
+				g.resetEscalation()
+			}
+			for lvl < n {
+				lvl++
+				g.escalate()
+			}
+			g.restartWave()
+			return fmt.Sprintf("now on level %d", lvl), nil
+		}},
+		"lives": &Command{"lives N", nil, func(g *Game, args []string) (string, error) {
+			n, err := consoleInt(args, 1, maxLives)
+			if err != nil {
+				return "", err
+			}
+			player.lives = n
+			return fmt.Sprintf("%d lives", n), nil
+		}},
+		"spawn": &Command{"spawn ufo", []string{"ufo"}, func(g *Game, args []string) (string, error) {
+			if len(args) != 1 || args[0] != "ufo" {
+				return "", fmt.Errorf("can only spawn ufo")
+			}
+			ufo = newUfo()
+			ufoTimer = 0
+			return "ufo spawned", nil
+		}},
+		"kill": &Command{"kill all", []string{"all"}, func(g *Game, args []string) (string, error) {
+			if len(args) != 1 || args[0] != "all" {
+				return "", fmt.Errorf("can only kill all")
+			}
+			n := 0
+			for i, a := range aliens {
+				if a != nil {
+					g.explode(a.x+alienSpriteWidth/2, a.y+alienSpriteHeight/2)
+					aliens[i] = nil
+					n++
+				}
+			}
+			return fmt.Sprintf("killed %d aliens", n), nil
+		}},
+		"god": &Command{"god on|off", []string{"on", "off"}, func(g *Game, args []string) (string, error) {
+			if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+				return "", fmt.Errorf("god must be on or off")
+			}
+			g.god = args[0] == "on"
+			return "god mode " + args[0], nil
+		}},
+		"speed": &Command{"speed X", nil, func(g *Game, args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("usage: speed X")
+			}
+			f, err := strconv.ParseFloat(args[0], 64)
+			if err != nil || f < minSimSpeed || f > maxSimSpeed {
+				return "", fmt.Errorf("speed must be a number from %g to %g", minSimSpeed, float64(maxSimSpeed))
+			}
+			simSpeed = f
+			return fmt.Sprintf("speed %g", f), nil
+		}},
+		"seed": &Command{"seed N", nil, func(g *Game, args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("usage: seed N")
+			}
+			n, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return "", fmt.Errorf("seed must be a whole number")
+			}
+			reseed(n)
+			return fmt.Sprintf("seeded with %d", n), nil
+		}},
+	}
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func consoleInt(args []string, min, max int) (int, error) {
+	if len(args) == 1 {
+		if n, err := strconv.Atoi(args[0]); err == nil && n >= min && n <= max {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("expected a number from %d to %d", min, max)
+}
+
+// invulnerable reports whether the player can't be hurt
+func (g *Game) invulnerable() bool {
+	return g.god || (g.practice && practice.invulnerable)
+}
+
+func (g *Game) toggleConsole() {
+	g.console = !g.console
+	conInput = ""
+	conHistoryPos = len(conHistory)
+}
+
+func conPrint(s string) {
+	conOutput = append(conOutput, s)
+	if len(conOutput) > consoleLines {
+		conOutput = conOutput[len(conOutput)-consoleLines:]
+	}
+}
+
+// runCommand runs a line typed into the console. Anything other than help
+// means the game no longer counts.
+func (g *Game) runCommand(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	conHistory = append(conHistory, line)
+	conPrint(consolePrompt + line)
+
+	c, ok := commands[fields[0]]
+	if !ok {
+		conPrint(fmt.Sprintf("unknown command %q, try help", fields[0]))
+		return
+	}
+	out, err := c.run(g, fields[1:])
+	if err != nil {
+		conPrint(err.Error())
+		return
+	}
+	if fields[0] != "help" {
+		g.cheated = true
+	}
+	conPrint(out)
+}
+
+// complete finishes the word being typed, as far as it can, and returns
+// what it could be if that isn't very far
+func complete(input string) (string, []string) {
+	fields := strings.Fields(input)
+	if strings.HasSuffix(input, " ") {
+		fields = append(fields, "")
+	}
+
+	var options []string
+	switch len(fields) {
+	case 0:
+		return input, nil
+	case 1:
+		options = commandNames()
+	case 2:
+		if c, ok := commands[fields[0]]; ok {
+			options = c.args
+		}
+	default:
+		return input, nil
+	}
+
+	word := fields[len(fields)-1]
+	matches := make([]string, 0)
+	for _, o := range options {
+		if strings.HasPrefix(o, word) {
+			matches = append(matches, o)
+		}
+	}
+	if len(matches) == 0 {
+		return input, nil
+	}
+
+	prefix := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(matches) > 1 && prefix == word {
+		return input, matches
+	}
+	fields[len(fields)-1] = prefix
+	completed := strings.Join(fields, " ")
+	if len(matches) == 1 {
+		completed += " "
+	}
+	return completed, nil
+}
+
+func (g *Game) DrawConsole() {
+	x, y := consoleMargin, scorey+2
+	w := g.w - 2*consoleMargin - 2
+	tbrect(x, y, w, consoleLines+1, fgConsole, bgConsole, true)
+
+	x += 2
+	for i, l := range conOutput {
+		tbprint(x, y+i, fgConsoleOut, bgConsole, l)
+	}
+	tbprint(x, y+consoleLines, fgConsole, bgConsole, consolePrompt+conInput+"_")
+}
+
+func (g *Game) HandleKeyConsole(k termbox.Key) {
+	switch k {
+	case termbox.KeyEnter:
+		g.runCommand(conInput)
+		conInput = ""
+		conHistoryPos = len(conHistory)
+	case termbox.KeyEsc:
+		g.toggleConsole()
+	case termbox.KeySpace:
+		conInput += " "
+	case termbox.KeyTab:
+		var options []string
+		conInput, options = complete(conInput)
+		if options != nil {
+			conPrint(strings.Join(options, " "))
+		}
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if r := []rune(conInput); len(r) > 0 {
+			conInput = string(r[:len(r)-1])
+		}
+	case termbox.KeyArrowUp:
+		if conHistoryPos > 0 {
+			conHistoryPos--
+			conInput = conHistory[conHistoryPos]
+		}
+	case termbox.KeyArrowDown:
+		if conHistoryPos < len(conHistory) {
+			conHistoryPos++
+		}
+		if conHistoryPos < len(conHistory) {
+			conInput = conHistory[conHistoryPos]
+		} else {
+			conInput = ""
+		}
+	}
+}
+
+func (g *Game) HandleCharConsole(ch rune) {
+	if ch == consoleKey {
+		g.toggleConsole()
+		return
+	}
+	conInput += string(ch)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// chance (1 in diveOdds) each frame that another alien peels away
+	diveOdds     = 45
+	maxDivers    = 4
+	diveSpeed    = 0.8
+	diveShootMax = 25
+	diveSwing    = 18
+	diveTopy     = -alienSpriteHeight
+)
+
+type Dive struct {
+	// cubic bezier from the alien's slot to wherever the dive ends
+	path [4][2]float64
+	t    float64
+	step float64
+
+	// dives that end off the bottom of the screen come back from the top
+	wrap bool
+
+	// flying back to the slot
+	returning bool
+}
+
+func bezier(p [4][2]float64, t float64) (float64, float64) {
+	u := 1 - t
+	a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+	return a*p[0][0] + b*p[1][0] + c*p[2][0] + d*p[3][0],
+		a*p[0][1] + b*p[1][1] + c*p[2][1] + d*p[3][1]
+}
+
+func (g *Game) newDive(a *Alien) *Dive {
+	d := &Dive{wrap: rng.Intn(2) == 0}
+
+	// swing out to one side, then cut across towards the player
+	side := float64(diveSwing)
+	if rng.Intn(2) == 0 {
+		side = -side
+	}
+	px := float64(player.x + player.ship.width/2 - alienSpriteWidth/2)
+	start := [2]float64{float64(a.x), float64(a.y)}
+	d.path[0] = start
+	d.path[1] = [2]float64{start[0] + side, start[1] - 2*alienSpriteHeight}
+	if d.wrap {
+		d.path[2] = [2]float64{px - side, float64(player.y) - 2*alienSpriteHeight}
+		d.path[3] = [2]float64{px + side/2, float64(g.h + 1)}
+	} else {
+		// loop back up to the slot from just above the barricades
+		d.path[2] = [2]float64{px - 2*side, float64(g.barricadeYPos() + barricadeSpriteHeight)}
+		d.path[3] = [2]float64{px + 2*side, float64(g.barricadeYPos() + barricadeSpriteHeight)}
+	}
+
+	length := 0.0
+	for i := 1; i < len(d.path); i++ {
+		length += math.Hypot(d.path[i][0]-d.path[i-1][0], d.path[i][1]-d.path[i-1][1])
+	}
+	d.step = diveSpeed / length
+	return d
+}
+
+// updateDives sends aliens out of the formation and flies the ones that
+// are already out. It reports whether one of them flew into the player.
+func (g *Game) updateDives(playerPos [][]int) bool {
+	divers := 0
+	for _, a := range aliens {
+		if a != nil && a.dive != nil {
+			divers++
+		}
+	}
+
+	if divers < maxDivers && rng.Intn(diveOdds) == 0 {
+		// only aliens with a clear path below them leave the formation
+		candidates := make([]*Alien, 0)
+		for i, a := range aliens {
+			if a == nil || a.dive != nil {
+				continue
+			}
+			clear := true
+			for j := i + aliensHorizontal; j < len(aliens) && j < aliensHorizontal*numRows; j += aliensHorizontal {
+				if aliens[j] != nil && aliens[j].dive == nil {
+					clear = false
+				}
+			}
+			if clear {
+				candidates = append(candidates, a)
+			}
+		}
+		if len(candidates) > 0 {
+			a := candidates[rng.Intn(len(candidates))]
+			a.dive = g.newDive(a)
+		}
+	}
+
+	for i, a := range aliens {
+		if a == nil || a.dive == nil {
+			continue
+		}
+		d := a.dive
+
+		if d.returning {
+			a.x += sign(a.home.x - a.x)
+			a.y += sign(a.home.y - a.y)
+			if a.x == a.home.x && a.y == a.home.y {
+				a.dive = nil
+			}
+			continue
+		}
+
+		d.t += d.step
+		if d.t >= 1 {
+			d.returning = true
+			if d.wrap {
+				a.y = diveTopy
+			}
+			continue
+		}
+		fx, fy := bezier(d.path, d.t)
+		a.x, a.y = int(math.Round(fx)), int(math.Round(fy))
+
+		// fire as they go
+		if a.y > 0 && a.y < player.y && rng.Intn(diveShootMax) == 0 {
+			g.alienFire(a)
+		}
+
+		if g.rammed(a, playerPos) {
+			aliens[i] = nil
+			g.explode(a.x+alienSpriteWidth/2, a.y+alienSpriteHeight/2)
+			return true
+		}
+	}
+
+	return false
+}
+
+// rammed reports whether a diving alien has flown into the player
+func (g *Game) rammed(a *Alien, playerPos [][]int) bool {
+	for y, l := range strings.Split(a.sprite[alienSpriteIndex], "\n") {
+		for x, c := range l {
+			px, py := a.x+x, a.y+y
+			if c != ' ' && px >= 0 && px < g.w && py >= 0 && py < g.h && playerPos[px][py] != nonIndex {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sign(i int) int {
+	switch {
+	case i < 0:
+		return -1
+	case i > 0:
+		return 1
+	}
+	return 0
+}
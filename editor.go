@@ -0,0 +1,384 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// EditorLayer is used as an enum
+type EditorLayer uint8
+
+const (
+	FormationLayer EditorLayer = iota
+	BarricadeLayer
+)
+
+const (
+	fgEditor          = neonGreen
+	bgEditor          = termbox.ColorBlack
+	fgEditorKey       = magenta
+	bgEditorCursor    = red
+	editorStatusy     = 1
+	editorHelpy       = 3
+	editorDefaultFile = "levels.txt"
+
+	editorSpeedStep = 1
+	editorFireStep  = 10
+	editorHelp1     = "Arrows move  Tab formation/barricades  %s place alien  x/Space toggle barricade  Del clear"
+	editorHelp2     = "+/- speed  [/] fire  0 auto  n name  PgUp/PgDn level  a add  d delete  p preview  w save  o open  ESC exit"
+)
+
+var (
+	edPack     []*Level
+	edLevel    int
+	edFilename string
+	edLayer    EditorLayer
+	edCol      int
+	edRow      int
+	edx        int
+	edy        int
+	edMsg      string
+
+	// text entry, used for level names and filenames
+	edPrompt  string
+	edInput   string
+	edOnInput func(string)
+
+	// the pack that was loaded with --levels, put back after a preview
+	edSavedLevels []*Level
+)
+
+func (g *Game) editorCols() int {
+	return (g.w - alienStartx) / (alienSpriteWidth + alienPadHorizontal)
+}
+
+func (g *Game) editorRows() int {
+	return (g.barricadeYPos() - initAlienStarty) / (alienSpriteHeight + alienPadVertical)
+}
+
+// newLevel lays out a level the same way the game does when it has no pack
+func (g *Game) newLevel() *Level {
+	g.wipePlay()
+	l := &Level{formation: defaultFormation()}
+
+	band := make([][]bool, barricadeSpriteHeight)
+	screen := g.genBarricades()
+	for y := range band {
+		band[y] = make([]bool, g.w)
+		for x := range band[y] {
+			band[y][x] = screen[x][g.barricadeYPos()+y] != nonIndex
+		}
+	}
+	l.barricades = bandBarricades(band)
+	return l
+}
+
+func (g *Game) edCurrent() *Level {
+	return edPack[edLevel]
+}
+
+// edRefresh keeps the cursors on the screen and puts the level being
+// edited into play so DrawPlay can draw it. It's done after anything that
+// could change either, rather than every frame.
+func (g *Game) edRefresh() {
+	if g.state != EditorState {
+		return
+	}
+	edCol = clamp(edCol, 0, g.editorCols()-1)
+	edRow = clamp(edRow, 0, g.editorRows()-1)
+	edx = clamp(edx, 0, g.w-1)
+	edy = clamp(edy, 0, barricadeSpriteHeight-1)
+	g.edShowLevel()
+}
+
+// edShowLevel puts the level being edited into play
+func (g *Game) edShowLevel() {
+	l := g.edCurrent()
+	alienStarty = initAlienStarty
+	alienSpriteIndex = 0
+	g.makeFormation(l.formation)
+	barricadePositions = g.makeBarricades(l.barricades)
+	alienBullets = nil
+	fragments = nil
+	ufo = nil
+
+	ship := ships[g.ship]
+	player = NewPlayer(g.w/2-ship.width/2, g.h-playerSpriteBottomOffset-playerSpriteHeight,
+		ship, ship.lives(g.rules().lives))
+}
+
+func (g *Game) edOpen(filename string) {
+	pack, err := loadLevels(filename)
+	if err == nil {
+		err = g.validateLevels(filename, pack)
+	}
+	if err != nil {
+		// the first problem is enough to go on
+		edMsg = strings.SplitN(err.Error(), "\n", 2)[0]
+		return
+	}
+
+	edPack, edLevel, edFilename = pack, 0, filename
+	edMsg = fmt.Sprintf("Opened %s", filename)
+}
+
+func (g *Game) edSave(filename string) {
+	if err := saveLevels(filename, edPack); err != nil {
+		edMsg = err.Error()
+		return
+	}
+	edFilename = filename
+	edMsg = fmt.Sprintf("Saved %d levels to %s", len(edPack), filename)
+}
+
+func (g *Game) edAsk(prompt, input string, f func(string)) {
+	edPrompt, edInput, edOnInput = prompt, input, f
+}
+
+// edStamp puts c into the formation at the cursor, growing it if needed
+func (g *Game) edStamp(c rune) {
+	l := g.edCurrent()
+	for len(l.formation) <= edRow {
+		l.formation = append(l.formation, "")
+	}
+	row := []rune(l.formation[edRow])
+	for len(row) <= edCol {
+		row = append(row, formationGap)
+	}
+	row[edCol] = c
+	l.formation[edRow] = string(row)
+}
+
+func (g *Game) edToggleBarricade(on bool) {
+	l := g.edCurrent()
+	band := g.barricadeBand(l)
+	band[edy][edx] = on
+	l.barricades = bandBarricades(band)
+}
+
+func (g *Game) DrawEditor() {
+	g.DrawPlay()
+
+	// DrawPlay's score and lives would only get in the way
+	tbprint(0, editorStatusy, fgEditor, bgEditor, strings.Repeat(" ", g.w))
+
+	l := g.edCurrent()
+	speed, fire := "auto", "auto"
+	if l.moveEvery != 0 {
+		speed = fmt.Sprintf("%d", l.moveEvery)
+	}
+	if l.shootValMax != 0 {
+		fire = fmt.Sprintf("%d", l.shootValMax)
+	}
+	layer := "formation"
+	if edLayer == BarricadeLayer {
+		layer = "barricades"
+	}
+	status := fmt.Sprintf("EDITOR  %s  level %d/%d %q  speed %s  fire %s  editing %s",
+		edFilename, edLevel+1, len(edPack), l.name, speed, fire, layer)
+	tbprint(1, editorStatusy, fgEditor, bgEditor, status)
+
+	if edOnInput != nil {
+		tbprint(1, editorStatusy+1, fgEditorKey, bgEditor, edPrompt+edInput+"_")
+	} else if edMsg != "" {
+		tbprint(1, editorStatusy+1, fgEditorKey, bgEditor, edMsg)
+	}
+	tbprint(1, editorHelpy, fgEditor, bgEditor, fmt.Sprintf(editorHelp1, alienKindChars()))
+	tbprint(1, editorHelpy+1, fgEditor, bgEditor, editorHelp2)
+
+	switch edLayer {
+	case FormationLayer:
+		x := alienStartx + edCol*(alienSpriteWidth+alienPadHorizontal)
+		y := initAlienStarty + edRow*(alienSpriteHeight+alienPadVertical)
+		highlight(x, y, alienSpriteWidth, alienSpriteHeight, bgEditorCursor)
+	case BarricadeLayer:
+		highlight(edx, g.barricadeYPos()+edy, 1, 1, bgEditorCursor)
+	}
+}
+
+// highlight changes the background of cells that have already been drawn
+func highlight(x, y, w, h int, bg termbox.Attribute) {
+	cells := termbox.CellBuffer()
+	tw, th := termbox.Size()
+	for j := y; j < y+h && j < th; j++ {
+		for i := x; i < x+w && i < tw; i++ {
+			c := cells[j*tw+i]
+			termbox.SetCell(i, j, c.Ch, c.Fg, bg)
+		}
+	}
+}
+
+func (g *Game) HandleKeyEditor(k termbox.Key) {
+	defer g.edRefresh()
+	if edOnInput != nil {
+		switch k {
+		case termbox.KeyEnter:
+			f := edOnInput
+			edOnInput = nil
+			f(edInput)
+		case termbox.KeyEsc:
+			edOnInput = nil
+		case termbox.KeySpace:
+			edInput += " "
+		case termbox.KeyBackspace, termbox.KeyBackspace2:
+			if r := []rune(edInput); len(r) > 0 {
+				edInput = string(r[:len(r)-1])
+			}
+		}
+		return
+	}
+
+	edMsg = ""
+	l := g.edCurrent()
+	switch k {
+	case termbox.KeyArrowLeft:
+		edCol--
+		edx--
+	case termbox.KeyArrowRight:
+		edCol++
+		edx++
+	case termbox.KeyArrowUp:
+		edRow--
+		edy--
+	case termbox.KeyArrowDown:
+		edRow++
+		edy++
+	case termbox.KeyTab:
+		edLayer = (edLayer + 1) % 2
+	case termbox.KeySpace:
+		if edLayer == BarricadeLayer {
+			g.edToggleBarricade(!g.barricadeBand(l)[edy][edx])
+		}
+	case termbox.KeyDelete, termbox.KeyBackspace, termbox.KeyBackspace2:
+		if edLayer == BarricadeLayer {
+			g.edToggleBarricade(false)
+		} else {
+			g.edStamp(formationGap)
+		}
+	case termbox.KeyPgup:
+		if edLevel > 0 {
+			edLevel--
+		}
+	case termbox.KeyPgdn:
+		if edLevel < len(edPack)-1 {
+			edLevel++
+		}
+	case termbox.KeyEsc:
+		player = nil
+		g.GoMenu()
+		g.hmi = Editor
+	}
+}
+
+func (g *Game) HandleCharEditor(ch rune) {
+	defer g.edRefresh()
+	if edOnInput != nil {
+		edInput += string(ch)
+		return
+	}
+
+	edMsg = ""
+	l := g.edCurrent()
+	if alienKind(ch) != nil && edLayer == FormationLayer {
+		g.edStamp(ch)
+		return
+	}
+
+	moveEvery, shootValMax := int(l.moveEvery), l.shootValMax
+	if moveEvery == 0 {
+		moveEvery = int(g.rules().moveEvery)
+	}
+	if shootValMax == 0 {
+		shootValMax = initAlienShootValMax
+	}
+
+	switch ch {
+	case formationGap:
+		if edLayer == FormationLayer {
+			g.edStamp(formationGap)
+		}
+	case 'x':
+		if edLayer == BarricadeLayer {
+			g.edToggleBarricade(!g.barricadeBand(l)[edy][edx])
+		}
+	case '+', '=':
+		l.moveEvery = uint8(clamp(moveEvery-editorSpeedStep, 1, maxMoveEvery))
+	case '-':
+		l.moveEvery = uint8(clamp(moveEvery+editorSpeedStep, 1, maxMoveEvery))
+	case ']':
+		l.shootValMax = clamp(shootValMax-editorFireStep, 1, maxShootValMax)
+	case '[':
+		l.shootValMax = clamp(shootValMax+editorFireStep, 1, maxShootValMax)
+	case '0':
+		l.moveEvery, l.shootValMax = 0, 0
+	case 'n':
+		g.edAsk("Level name: ", l.name, func(s string) {
+			l.name = strings.TrimSpace(s)
+		})
+	case 'a':
+		edPack = append(edPack[:edLevel+1], append([]*Level{g.newLevel()}, edPack[edLevel+1:]...)...)
+		edLevel++
+	case 'd':
+		if len(edPack) > 1 {
+			edPack = append(edPack[:edLevel], edPack[edLevel+1:]...)
+			if edLevel == len(edPack) {
+				edLevel--
+			}
+		}
+	case 'p':
+		g.edPreview()
+	case 'w':
+		g.edAsk("Save as: ", edFilename, g.edSave)
+	case 'o':
+		g.edAsk("Open: ", edFilename, g.edOpen)
+	}
+}
+
+// edPreview plays the level being edited
+func (g *Game) edPreview() {
+	level := []*Level{g.edCurrent()}
+	if err := g.validateLevels(edFilename, level); err != nil {
+		edMsg = "Doesn't fit: " + err.(LevelErrors)[0].msg
+		return
+	}
+	g.preview = true
+	edSavedLevels = levels
+	levels = level
+	player = nil
+	g.GoPlay()
+}
+
+func (g *Game) endPreview() {
+	g.preview = false
+	levels = edSavedLevels
+	g.wipePlay()
+	g.GoEditor()
+}
+
+func (g *Game) GoEditor() {
+	g.state = EditorState
+	g.cfg = fgPlay
+	g.cbg = bgPlay
+
+	if edPack == nil {
+		edFilename = editorDefaultFile
+		edPack = []*Level{g.newLevel()}
+		if levelsFile != "" {
+			g.edOpen(levelsFile)
+		}
+	}
+	edLevel = clamp(edLevel, 0, len(edPack)-1)
+	g.edRefresh()
+}
+
+func clamp(v, min, max int) int {
+	switch {
+	case v < min:
+		return min
+	case v > max:
+		return max
+	}
+	return v
+}
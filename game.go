@@ -1,34 +1,26 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/nsf/termbox-go"
 	"github.com/simulatedsimian/joystick"
 )
 
-type Highscore struct {
-	score int
-	name  string
-}
-
-type ByScore []*Highscore
-
-func (a ByScore) Len() int           { return len(a) }
-func (a ByScore) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByScore) Less(i, j int) bool { return a[i].score < a[j].score }
-
 func tbprint(x, y int, fg, bg termbox.Attribute, msg string) {
 	for _, c := range msg {
+		w := runewidth.RuneWidth(c)
+		if w == 0 {
+			continue
+		}
 		termbox.SetCell(x, y, c, fg, bg)
-		x++
+		x += w
 	}
 }
 
@@ -53,6 +45,21 @@ func tbrect(x, y, w, h int, fg, bg termbox.Attribute, border bool) {
 	}
 }
 
+// tbprintPrompt prints a line such as "Press ", "ESC ", "to go back",
+// centred, with every other part, the keys, picked out
+func tbprintPrompt(y int, parts ...string) {
+	w, _ := termbox.Size()
+	x := w/2 - len(strings.Join(parts, ""))/2
+	for i, s := range parts {
+		if i%2 == 1 {
+			tbprint(x, y, fgPromptKey, bgPrompt, s)
+		} else {
+			tbprint(x, y, fgPrompt, bgPrompt, s)
+		}
+		x += len(s)
+	}
+}
+
 // print a multi-line sprite
 func tbprintsprite(x, y int, fg, bg termbox.Attribute, sprite string) {
 	lines := strings.Split(sprite, "\n")
@@ -65,9 +72,12 @@ func tbprintsprite(x, y int, fg, bg termbox.Attribute, sprite string) {
 const (
 	highscoreFilename  = "hs"
 	highscoreSeparator = ":"
-	maxHighscores      = 5
+	maxHighscores      = 100
 	fgDefault          = termbox.ColorRed
 	bgDefault          = termbox.ColorYellow
+	fgPrompt           = neonGreen
+	bgPrompt           = termbox.ColorBlack
+	fgPromptKey        = magenta
 	fps                = 30
 )
 
@@ -80,10 +90,54 @@ const (
 	PlayState
 	HighscoresState
 	WarnState
+	ModesState
+	EditorState
+	ShipsState
+	AchievementsState
+	StatsState
+	PracticeState
+	ReplaysState
+	ReplayState
 )
 
 type Game struct {
-	highscores []*Highscore
+	// every board's highscores, see scores.go, and why they couldn't be
+	// loaded or saved if they couldn't
+	highscores        []*Highscore
+	highscoresErr     error
+	highscoresSaveErr error
+
+	// entries in the file that didn't make sense
+	highscoresSkipped int
+
+	// the key the highscores are signed with, whether it was made this
+	// run, and whether the signature didn't match, see signature.go
+	highscoresKey      []byte
+	keyCreated         bool
+	highscoresModified bool
+
+	// when each achievement was unlocked, by id
+	achievements map[string]time.Time
+
+	stats *LifetimeStats
+
+	// mode being played, and highscore table being viewed
+	mode       GameMode
+	hsBoard    Board
+	difficulty Difficulty
+
+	// the GLOBAL tab once it's been fetched, and where it's coming from
+	// until then
+	global        *GlobalResult
+	globalResults chan *GlobalResult
+
+	// how far down the highscore table is scrolled, and the key of the
+	// last highscore set, which is highlighted
+	hsScroll      int
+	lastHighscore string
+
+	// index into ships
+	ship int
 
 	state GameState
 	evq   chan termbox.Event
@@ -91,9 +145,50 @@ type Game struct {
 
 	js joystick.Joystick
 
+	// joystick keys held down, and for how many frames, see
+	// joystickPresses
+	jsHeld map[termbox.Key]int
+
 	// frame counter
 	fc uint8
 
+	// aliens break formation to dive at the player
+	dives bool
+
+	// playing a level from the editor
+	preview bool
+
+	// playing a practice game, see practice
+	practice bool
+
+	// the developer console is open, god mode is on, and the console has
+	// been used this game so it doesn't count
+	console bool
+	god     bool
+	cheated bool
+
+	// playing a replay without a screen, see verifyHighscore
+	headless bool
+
+	// saved replays on the REPLAYS screen, the one selected, how far the
+	// list is scrolled and why the last one picked couldn't be played
+	replays      []*SavedReplay
+	replaySel    int
+	replayScroll int
+	replaysMsg   string
+
+	// the replay being watched, whether it's paused, and its index into
+	// replaySpeeds
+	playback     *Playback
+	replayPaused bool
+	replaySpeed  int
+
+	// seed for the next game, or 0 for a random one
+	seed int64
+
+	// why the last game picked couldn't be started, see startPlay
+	startMsg string
+
 	// highlighted menu item
 	hmi int
 	w   int
@@ -106,10 +201,12 @@ type Game struct {
 
 func NewGame() *Game {
 	return &Game{
-		highscores: make([]*Highscore, 0),
-		evq:        make(chan termbox.Event),
-		timer:      time.Tick(time.Duration(1000/fps) * time.Millisecond),
-		fc:         1,
+		achievements: make(map[string]time.Time),
+		stats:        newStats(),
+		difficulty:   Normal,
+		evq:          make(chan termbox.Event),
+		timer:        time.Tick(time.Duration(1000/fps) * time.Millisecond),
+		fc:           1,
 	}
 }
 
@@ -130,7 +227,25 @@ func (g *Game) Listen() {
 	}()
 }
 
+// drainEvents throws away the keys pressed before now, such as Space still
+// being pressed when the game ended, waiting until a tick goes by without
+// any so that ones termbox has yet to pass on go too
+func (g *Game) drainEvents() {
+	for {
+		select {
+		case <-g.evq:
+		case <-time.After(time.Second / fps):
+			return
+		}
+	}
+}
+
 func (g *Game) HandleKey(k termbox.Key) {
+	if g.console {
+		g.HandleKeyConsole(k)
+		return
+	}
+
 	switch g.state {
 	case MenuState:
 		g.HandleKeyMenu(k)
@@ -142,12 +257,52 @@ func (g *Game) HandleKey(k termbox.Key) {
 		g.HandleKeyHighscores(k)
 	case WarnState:
 		g.HandleKeyWarn(k)
+	case ModesState:
+		g.HandleKeyModes(k)
+	case EditorState:
+		g.HandleKeyEditor(k)
+	case ShipsState:
+		g.HandleKeyShips(k)
+	case PracticeState:
+		g.HandleKeyPractice(k)
+	case StatsState:
+		g.HandleKeyStats(k)
+	case AchievementsState:
+		g.HandleKeyAchievements(k)
+	case ReplaysState:
+		g.HandleKeyReplays(k)
+	case ReplayState:
+		g.HandleKeyReplay(k)
 	}
 }
 
+// HandleChar is for the screens that take more than the arrow keys
+func (g *Game) HandleChar(ch rune) {
+	if g.console {
+		g.HandleCharConsole(ch)
+		return
+	}
+
+	switch g.state {
+	case PlayState:
+		g.HandleCharPlay(ch)
+	case EditorState:
+		g.HandleCharEditor(ch)
+	case ReplayState:
+		g.HandleCharReplay(ch)
+	}
+}
+
+// typing reports whether the player is entering text, so q shouldn't quit
+func (g *Game) typing() bool {
+	return g.console || (g.state == EditorState && edOnInput != nil)
+}
+
 func (g *Game) FitScreen() {
 	termbox.Clear(g.cfg, g.cbg)
 	g.w, g.h = termbox.Size()
+	// the editor's cursor and level have to fit the new size
+	g.edRefresh()
 	g.Draw()
 }
 
@@ -161,13 +316,32 @@ func (g *Game) Draw() {
 		g.DrawHowto()
 	case PlayState:
 		g.DrawPlay()
+		if g.console {
+			g.DrawConsole()
+		}
 	case HighscoresState:
 		g.DrawHighscores()
 	case WarnState:
 		g.DrawWarn()
+	case ModesState:
+		g.DrawModes()
+	case EditorState:
+		g.DrawEditor()
+	case ShipsState:
+		g.DrawShips()
+	case PracticeState:
+		g.DrawPractice()
+	case StatsState:
+		g.DrawStats()
+	case AchievementsState:
+		g.DrawAchievements()
+	case ReplaysState:
+		g.DrawReplays()
+	case ReplayState:
+		g.DrawReplay()
 	}
 
-	termbox.Flush()
+	flush()
 }
 
 func (g *Game) ReadJoystick() {
@@ -177,10 +351,10 @@ func (g *Game) ReadJoystick() {
 			if jstate.Buttons&1 != 0 {
 				g.HandleKey(termbox.KeySpace)
 			}
-			if jstate.AxisData[0] < -10000 {
+			if jstate.AxisData[0] < -jsDeadzone {
 				g.HandleKey(termbox.KeyArrowLeft)
 			}
-			if jstate.AxisData[0] > 10000 {
+			if jstate.AxisData[0] > jsDeadzone {
 				g.HandleKey(termbox.KeyArrowRight)
 			}
 		}
@@ -196,113 +370,150 @@ func (g *Game) Update() {
 	case HowtoState:
 		g.UpdateHowto()
 	case PlayState:
+		if g.console {
+			break
+		}
 		g.ReadJoystick()
-		g.UpdatePlay()
+		for i := simSteps(); i > 0 && g.state == PlayState; i-- {
+			g.UpdatePlay()
+		}
 	case HighscoresState:
 		g.UpdateHighscores()
+	case ModesState:
+		g.UpdateModes()
+	case ShipsState:
+		g.UpdateShips()
+	case PracticeState:
+		g.UpdatePractice()
+	case StatsState:
+		g.UpdateStats()
+	case AchievementsState:
+		g.UpdateAchievements()
+	case ReplaysState:
+		g.UpdateReplays()
+	case ReplayState:
+		g.UpdateReplay()
 	}
 
 	return
 }
 
-func (g *Game) loadHighscores() {
-	data, err := ioutil.ReadFile(highscoreFilename)
-	if err != nil {
-		log.Fatalln(err)
+func (g *Game) checkSize() bool {
+	if g.w < logoLineLength+8 || g.h < (logoY+logoHeight+5+2) {
+		return false
 	}
-	lines := strings.Split(string(data), "\n")
-	if len(lines) == 0 {
-		return
+	return true
+}
+
+// subcommands are run instead of the game, as spaceinvaders [flags] name
+var subcommands = map[string]func(args []string) error{
+	"verify": verifyCommand,
+	"serve":  serveCommand,
+	"scores": scoresCommand,
+}
+
+func main() {
+	levelsFilename := flag.String("levels", "", "play the levels in `file` instead of generating them")
+	dataDir := flag.String("data-dir", "", "keep highscores, achievements and stats in `dir`")
+	configDir := flag.String("config-dir", "", "keep settings in `dir`")
+	stateDir := flag.String("state-dir", "", "write the log to `dir`")
+	server := flag.String("server", "", "send highscores to the leaderboard server at `url` and show its GLOBAL tab")
+	record := flag.String("record", "", "record the session to `file` in asciicast v2 format")
+	shared := flag.String("shared", "", "keep highscores in the shared `file`, such as /var/games/spaceinvaders.scores")
+	flag.Parse()
+	serverURL = *server
+
+	if err := resolveDirs(*dataDir, *configDir, *stateDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	for _, l := range lines {
-		parts := strings.Split(l, highscoreSeparator)
-		if len(parts) != 2 {
-			log.Println("highscores file has been corrupted - please correct/delete it")
-			continue
-		} else if len(parts[0]) < 3 || len(parts[0]) > 10 {
-			log.Println("highscore file has been corrupted (name too long/short) - please correct/delete it")
-			continue
-		}
-		if i, err := strconv.Atoi(parts[1]); err == nil {
-			if i < 0 {
-				log.Println("negative highscore found - data corrupted - please correct/delete the hs file")
-				continue
-			}
-			g.highscores = append(g.highscores, &Highscore{i, parts[0]})
-		} else {
-			log.Fatalln(err)
+
+	if *levelsFilename != "" {
+		pack, err := loadLevels(*levelsFilename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		levels, levelsFile = pack, *levelsFilename
 	}
 
-	sort.Sort(sort.Reverse(ByScore(g.highscores)))
-	if len(g.highscores) > 5 {
-		g.highscores = g.highscores[:5]
+	if *shared != "" {
+		sharedErr = useSharedHighscores(*shared)
 	}
-}
 
-func loadHighscoresData(data []byte) error {
-	highscores := make([]*Highscore, 0)
-	lines := strings.Split(string(data), "\n")
-	if len(lines) == 0 {
-		return fmt.Errorf("no highscore data in file")
-	}
-	for _, l := range lines {
-		parts := strings.Split(l, highscoreSeparator)
-		if len(parts) != 2 {
-			return fmt.Errorf("corrupted highscore data")
+	if flag.NArg() > 0 {
+		if sharedErr != nil {
+			fmt.Fprintln(os.Stderr, "using your own highscores:", sharedErr)
 		}
-		if i, err := strconv.Atoi(parts[1]); err == nil {
-			if i < 0 {
-				return fmt.Errorf("negative highscore - data corrupted")
-			}
-			highscores = append(highscores, &Highscore{i, parts[0]})
-		} else {
-			return err
+		run, ok := subcommands[flag.Arg(0)]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(2)
 		}
+		if err := run(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	sort.Sort(sort.Reverse(ByScore(highscores)))
-	if len(highscores) > 5 {
-		highscores = highscores[:5]
-	}
-	return nil
-}
-
-func Fuzz(data []byte) int {
-	if err := loadHighscoresData(data); err != nil {
-		return 0
+	f, err := os.Create(statePath(logFilename))
+	if err != nil {
+		log.Fatalln(err)
 	}
-	return 1
-}
 
-func (g *Game) checkSize() bool {
-	if g.w < logoLineLength+8 || g.h < (logoY+logoHeight+5+2) {
-		return false
+	var cast *os.File
+	if *record != "" {
+		if cast, err = os.Create(*record); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	}
-	return true
-}
 
-func main() {
 	if err := termbox.Init(); err != nil {
 		log.Fatalln(err)
 	}
 	termbox.SetOutputMode(termbox.Output256)
 	defer termbox.Close()
 
-	f, err := os.Create("diwe.log")
-	if err != nil {
-		log.Fatalln(err)
-	}
 	log.SetOutput(f)
 
+	if cast != nil {
+		w, h := termbox.Size()
+		if recorder, err = newRecorder(cast, w, h); err != nil {
+			log.Printf("not recording: %v", err)
+			cast.Close()
+		}
+		defer func() {
+			if recorder != nil {
+				if err := recorder.Close(); err != nil {
+					log.Println(err)
+				}
+			}
+		}()
+	}
+
 	g := NewGame()
+	g.w, g.h = termbox.Size()
+
+	if levels != nil {
+		if err := g.validateLevels(*levelsFilename, levels); err != nil {
+			termbox.Close()
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
 
 	js, _ := joystick.Open(0)
 	g.js = js
 
-	if _, err := os.Stat(highscoreFilename); err == nil {
-		g.loadHighscores()
+	if sharedErr != nil {
+		log.Printf("using your own highscores: %v", sharedErr)
 	}
+	g.loadHighscores()
+	g.loadAchievements()
+	g.loadStats()
 
 	g.Listen()
 	g.FitScreen()
@@ -321,9 +532,10 @@ main:
 			case termbox.EventKey:
 				switch ev.Key {
 				case 0:
-					if ev.Ch == 'q' {
+					if ev.Ch == 'q' && !g.typing() {
 						break main
 					}
+					g.HandleChar(ev.Ch)
 				default:
 					g.HandleKey(ev.Key)
 				}
@@ -1,9 +1,11 @@
 module github.com/asib/spaceinvaders
 
-go 1.16
+go 1.18
 
 require (
+	github.com/mattn/go-runewidth v0.0.9
 	github.com/nsf/termbox-go v0.0.0-20210114135735-d04385b850e8
 	github.com/simulatedsimian/joystick v1.0.1
-	golang.org/x/sys v0.0.0-20210220050731-9a76102bfb43 // indirect
 )
+
+require golang.org/x/sys v0.0.0-20210220050731-9a76102bfb43 // indirect
@@ -3,59 +3,217 @@ package main
 import (
 	"fmt"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/nsf/termbox-go"
 )
 
 const (
 	fgHighscores       = neonGreen
 	bgHighscores       = termbox.ColorBlack
-	highscoresWidthPad = 7
-	scorePad           = 10
-	namePad            = 10
-	highscoresHeight   = maxHighscores + 6
+	fgHighscoresHeader = magenta
+	highscoresWidthPad = 5
+	highscoreRow       = "%5s %s %10s %5s %6s %-10s %-8s"
+	fgHighscoreRecent  = termbox.ColorBlack
+	bgHighscoreRecent  = neonGreen
+	scrollUpMark       = "^"
+	scrollDownMark     = "v"
+	failedMark         = "x"
+	failedLegend       = failedMark + " = replay doesn't match the score"
+	modifiedWarning    = "Scores modified externally"
+	highscoreDateFmt   = "2006-01-02"
+	fgHighscoresErr    = red
+	saveFailedText     = "COULDN'T SAVE YOUR HIGHSCORE"
 	title              = "HIGHSCORES"
-	prompt             = "Press ESC to exit"
+
+	// rows of the table shown at once, fewer if the terminal is short
+	maxHighscoreRows = 10
+	minHighscoreRows = 3
 )
 
+// highscoreColumns formats a highscore for the table, with - for anything
+// scores from older versions don't have
+func highscoreColumns(rank int, hs *Highscore) string {
+	level, duration, date := "-", "-", "-"
+	if hs.Level > 0 {
+		level = fmt.Sprintf("%d", hs.Level)
+	}
+	if hs.Duration > 0 {
+		duration = fmt.Sprintf("%d:%02d", hs.Duration/60, hs.Duration%60)
+	}
+	if !hs.Date.IsZero() {
+		date = hs.Date.Format(highscoreDateFmt)
+	}
+	return fmt.Sprintf(highscoreRow, fmt.Sprintf("%d.", rank), padName(hs.Name), fmt.Sprintf("%d", hs.Score), level,
+		duration, date, hs.Ship)
+}
+
+// highscoresMessage explains what went wrong if the highscores couldn't all
+// be loaded, saved or fetched, or what the mark next to a failed replay means
+func (g *Game) highscoresMessage() string {
+	switch {
+	case g.hsBoard.global && g.global == nil:
+		return globalLoading
+	case g.hsBoard.global && g.global.err != nil:
+		return "Couldn't reach the server: " + g.global.err.Error()
+	case g.highscoresErr != nil:
+		return "Couldn't load highscores: " + g.highscoresErr.Error()
+	case g.highscoresSaveErr != nil:
+		return "Couldn't save highscores: " + g.highscoresSaveErr.Error()
+	case g.highscoresModified:
+		return modifiedWarning
+	case sharedErr != nil:
+		return "Couldn't use the shared highscores, see " + logFilename
+	case g.highscoresSkipped == 1:
+		return "1 entry skipped, see " + logFilename
+	case g.highscoresSkipped > 1:
+		return fmt.Sprintf("%d entries skipped, see %s", g.highscoresSkipped, logFilename)
+	}
+	for _, hs := range g.board(g.hsBoard) {
+		if hs.Verified == verifiedFailed {
+			return failedLegend
+		}
+	}
+	return ""
+}
+
+// boardIndex finds b in tabs, or returns 0 if it isn't there
+func boardIndex(tabs []Board, b Board) int {
+	for i, t := range tabs {
+		if t == b {
+			return i
+		}
+	}
+	return 0
+}
+
+// highscoreRows is how many rows of the table fit on the screen
+func (g *Game) highscoreRows() int {
+	spare := g.h - logoY - 10
+	if g.highscoresMessage() != "" {
+		spare -= 2
+	}
+	switch {
+	case spare > maxHighscoreRows:
+		return maxHighscoreRows
+	case spare < minHighscoreRows:
+		return minHighscoreRows
+	}
+	return spare
+}
+
+// scrollHighscores moves the table by d rows, as far as it will go
+func (g *Game) scrollHighscores(d int) {
+	g.hsScroll += d
+	if last := len(g.board(g.hsBoard)) - g.highscoreRows(); g.hsScroll > last {
+		g.hsScroll = last
+	}
+	if g.hsScroll < 0 {
+		g.hsScroll = 0
+	}
+}
+
+// showBoard switches to b, scrolled to the last highscore set if it's there
+func (g *Game) showBoard(b Board) {
+	g.hsBoard, g.hsScroll = b, 0
+	if b.global {
+		g.fetchGlobal(b)
+		return
+	}
+	for i, hs := range g.board(b) {
+		if hs.key() == g.lastHighscore {
+			g.scrollHighscores(i - g.highscoreRows()/2)
+		}
+	}
+}
+
 func (g *Game) DrawHighscores() {
 	g.DrawMenu()
 
-	w, h := scorePad+1+namePad+2*highscoresWidthPad, highscoresHeight
+	header := fmt.Sprintf(highscoreRow, "#", padName("NAME"), "SCORE", "LEVEL", "TIME", "DATE", "SHIP")
+	rows := g.highscoreRows()
+	w, h := len(header)+2*highscoresWidthPad, rows+9
+	msg := g.highscoresMessage()
+	if msg != "" {
+		h += 2
+		// by width, as paths in errors can have wide characters
+		msg = runewidth.Truncate(msg, w-4, "...")
+	}
 	x, y := g.w/2-w/2, logoY
 	tbrect(x, y, w, h, fgHighscores, bgHighscores, true)
+	markx := x + w - highscoresWidthPad + 1
 
 	y += 2
 	tbprint(g.w/2-len(title)/2, y, fgHighscores, bgHighscores, title)
 
+	y += 2
+	tabs := g.boardTabs()
+	tab := fmt.Sprintf("< %s  %d/%d >", g.hsBoard, boardIndex(tabs, g.hsBoard)+1, len(tabs))
+	tbprint(g.w/2-len(tab)/2, y, magenta, bgHighscores, tab)
+
 	y += 2
 	x += highscoresWidthPad
-	for _, hs := range g.highscores {
-		tbprint(x, y, fgHighscores, bgHighscores, fmt.Sprintf("%-10s %010d", hs.name, hs.score))
+	tbprint(x, y, fgHighscoresHeader, bgHighscores, header)
+	y++
+	highscores := g.board(g.hsBoard)
+	g.scrollHighscores(0)
+	if g.hsScroll > 0 {
+		tbprint(markx, y, magenta, bgHighscores, scrollUpMark)
+	}
+	for i := g.hsScroll; i < g.hsScroll+rows; i++ {
+		switch {
+		case i >= len(highscores):
+			tbprint(x, y, fgHighscores, bgHighscores, fmt.Sprintf(highscoreRow, fmt.Sprintf("%d.", i+1), padName("?????"),
+				"0", "", "", "", ""))
+		case highscores[i].key() == g.lastHighscore:
+			tbprint(x, y, fgHighscoreRecent, bgHighscoreRecent, highscoreColumns(i+1, highscores[i]))
+		default:
+			tbprint(x, y, fgHighscores, bgHighscores, highscoreColumns(i+1, highscores[i]))
+		}
+		if i < len(highscores) && highscores[i].Verified == verifiedFailed {
+			tbprint(x, y, fgHighscoresErr, bgHighscores, failedMark)
+		}
 		y++
 	}
-	for i := 0; i < maxHighscores-len(g.highscores); i++ {
-		tbprint(x, y, fgHighscores, bgHighscores, fmt.Sprintf("%-10s %010d", "?????", 0))
+	if g.hsScroll+rows < len(highscores) {
+		tbprint(markx, y-1, magenta, bgHighscores, scrollDownMark)
+	}
+
+	if msg != "" {
+		y++
+		tbprint(g.w/2-runewidth.StringWidth(msg)/2, y, fgHighscoresErr, bgHighscores, msg)
 		y++
 	}
 
 	y++
-	x = g.w/2 - len(prompt)/2
-	p1 := "Press "
-	p2 := "ESC "
-	p3 := "to exit"
-	tbprint(x, y, fgHighscores, bgHighscores, p1)
-	x += len(p1)
-	tbprint(x, y, magenta, bgHighscores, p2)
-	x += len(p2)
-	tbprint(x, y, fgHighscores, bgHighscores, p3)
+	tbprintPrompt(y, "Press ", "ESC ", "to exit")
 }
 
 func (g *Game) UpdateHighscores() {
 	g.UpdateMenu()
+
+	select {
+	case r := <-g.globalResults:
+		g.global, g.globalResults = r, nil
+	default:
+	}
 }
 
 func (g *Game) HandleKeyHighscores(k termbox.Key) {
 	switch k {
+	case termbox.KeyArrowLeft:
+		tabs := g.boardTabs()
+		g.showBoard(tabs[(boardIndex(tabs, g.hsBoard)-1+len(tabs))%len(tabs)])
+	case termbox.KeyArrowRight:
+		tabs := g.boardTabs()
+		g.showBoard(tabs[(boardIndex(tabs, g.hsBoard)+1)%len(tabs)])
+	case termbox.KeyArrowUp:
+		g.scrollHighscores(-1)
+	case termbox.KeyArrowDown:
+		g.scrollHighscores(1)
+	case termbox.KeyPgup:
+		g.scrollHighscores(-g.highscoreRows())
+	case termbox.KeyPgdn:
+		g.scrollHighscores(g.highscoreRows())
 	case termbox.KeyEsc:
 		g.GoMenu()
 		g.hmi = Highscores
@@ -63,6 +221,7 @@ func (g *Game) HandleKeyHighscores(k termbox.Key) {
 }
 
 func (g *Game) GoHighscores() {
+	g.showBoard(g.currentBoard())
 	g.state = HighscoresState
 	g.cfg = fgMenu
 	g.cbg = bgMenu
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/nsf/termbox-go"
 )
@@ -18,36 +20,15 @@ const (
 	fgHowtoUfo       = magenta
 	bgHowtoUfo       = termbox.ColorBlack
 	fgHowtoControl   = magenta
-	instructionsWPad = 20
+	instructionsWPad = 10
 	instructionsHPad = 2
+	howtoColumns     = 3
+	howtoSpriteWidth = 11
+	howtoTextWidth   = 14
 )
 
 var (
-	instructions = []AttributedText{
-		AttributedText{fgHowto, bgHowto, `  xx
- xOOx
-xxxxxx     = 10 pts
- /\/\
-
-x    x
-xxOOxx
- xxxx      = 20 pts
- /  \
-
-  xx
-xOxxOx
-xxxxxx     = 30 pts
- /||\
-`},
-		AttributedText{fgHowtoUfo, bgHowtoUfo, `
-  xxxxx
-xxoxOxoxx`},
-		AttributedText{fgHowto, bgHowto, `  = ?? pts`},
-		AttributedText{fgHowtoUfo, bgHowtoUfo, `
- ##   ##
-
-
-`},
+	controls = []AttributedText{
 		AttributedText{fgHowtoControl, bgHowto, `Left`},
 		AttributedText{fgHowto, bgHowto, `/`},
 		AttributedText{fgHowtoControl, bgHowto, `Right`},
@@ -63,21 +44,72 @@ Press `},
 		AttributedText{fgHowtoControl, bgHowto, `ESC`},
 		AttributedText{fgHowto, bgHowto, ` to close
 this window.`}}
+	instructions       []AttributedText
 	instructionsLines  []string
 	instructionsWidth  int
 	instructionsHeight int
 )
 
 func init() {
+	instructions = append(alienInstructions(), controls...)
+
 	str := ""
 	for _, v := range instructions {
 		str += v.s
 	}
 	instructionsLines = strings.Split(str, "\n")
-	instructionsWidth = len(instructionsLines[2])
+	for _, l := range instructionsLines {
+		if n := utf8.RuneCountInString(l); n > instructionsWidth {
+			instructionsWidth = n
+		}
+	}
 	instructionsHeight = len(instructionsLines)
 }
 
+// alienInstructions lays out every kind of alien, and the UFO, in a grid
+func alienInstructions() []AttributedText {
+	type entry struct {
+		fg     termbox.Attribute
+		sprite string
+		text   []string
+	}
+
+	entries := make([]entry, 0, len(alienKinds)+1)
+	for _, k := range alienKinds {
+		entries = append(entries, entry{k.fg, k.sprite[0],
+			[]string{"", fmt.Sprintf("%s (%c)", k.name, k.char), fmt.Sprintf("= %d pts", k.reward), k.blurb}})
+	}
+	entries = append(entries, entry{fgHowtoUfo, ufoSprite, []string{"", "UFO", "= ?? pts", ""}})
+
+	text := make([]AttributedText, 0)
+	for r := 0; r < len(entries); r += howtoColumns {
+		row := entries[r:]
+		if len(row) > howtoColumns {
+			row = row[:howtoColumns]
+		}
+		for line := 0; line < alienSpriteHeight; line++ {
+			for i, e := range row {
+				sprite, words := "", ""
+				if l := strings.Split(e.sprite, "\n"); line < len(l) {
+					sprite = l[line]
+				}
+				if line < len(e.text) {
+					words = e.text[line]
+				}
+				if i != len(row)-1 {
+					words = fmt.Sprintf("%-*s", howtoTextWidth, words)
+				}
+				text = append(text,
+					AttributedText{e.fg, bgHowto, fmt.Sprintf("%-*s", howtoSpriteWidth, sprite)},
+					AttributedText{fgHowto, bgHowto, words})
+			}
+			text = append(text, AttributedText{fgHowto, bgHowto, "\n"})
+		}
+		text = append(text, AttributedText{fgHowto, bgHowto, "\n"})
+	}
+	return text
+}
+
 func (g *Game) DrawHowto() {
 	g.DrawMenu()
 
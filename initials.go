@@ -0,0 +1,175 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	// what each initial can be, cycled through with Up and Down
+	initialChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.-!?*#&@"
+	numInitials  = minNameLen
+
+	initialsPrompt = "Enter your initials:"
+	initialsHelp   = "Up/Down to change, Left/Right to move, FIRE to finish"
+	initialsTab    = "Tab to type a name instead"
+	initialsHeight = 13
+	initialsWPad   = 6
+	initialsGap    = 3
+	fgInitials     = white
+	bgInitials     = termbox.ColorBlack
+
+	// axis values nearer the middle than this are ignored
+	jsDeadzone = 10000
+
+	// frames a direction is held before it repeats, and how often it then
+	// does
+	jsRepeatDelay = fps / 2
+	jsRepeatEvery = fps / 10
+)
+
+// joystickKeys are the keys the joystick can press, and whether they repeat
+// when held
+var joystickKeys = []struct {
+	key     termbox.Key
+	repeats bool
+}{
+	{termbox.KeySpace, false},
+	{termbox.KeyArrowLeft, false},
+	{termbox.KeyArrowRight, false},
+	{termbox.KeyArrowUp, true},
+	{termbox.KeyArrowDown, true},
+}
+
+// joystickPresses returns the keys the joystick has just pressed, rather
+// than the ones it's holding down like ReadJoystick
+func (g *Game) joystickPresses() []termbox.Key {
+	if g.js == nil {
+		return nil
+	}
+	st, err := g.js.Read()
+	if err != nil || len(st.AxisData) < 2 {
+		return nil
+	}
+	down := map[termbox.Key]bool{
+		termbox.KeySpace:      st.Buttons&1 != 0,
+		termbox.KeyArrowLeft:  st.AxisData[0] < -jsDeadzone,
+		termbox.KeyArrowRight: st.AxisData[0] > jsDeadzone,
+		termbox.KeyArrowUp:    st.AxisData[1] < -jsDeadzone,
+		termbox.KeyArrowDown:  st.AxisData[1] > jsDeadzone,
+	}
+
+	presses := make([]termbox.Key, 0)
+	for _, k := range joystickKeys {
+		if !down[k.key] {
+			delete(g.jsHeld, k.key)
+			continue
+		}
+		n := g.jsHeld[k.key]
+		g.jsHeld[k.key] = n + 1
+		if n == 0 || (k.repeats && n >= jsRepeatDelay && (n-jsRepeatDelay)%jsRepeatEvery == 0) {
+			presses = append(presses, k.key)
+		}
+	}
+	return presses
+}
+
+// startInitials fills the slots from the last name, if it fits, or with As
+func startInitials() []int {
+	slots := make([]int, numInitials)
+	last := loadLastName()
+	if len(last) != numInitials {
+		return slots
+	}
+	for i := range slots {
+		j := strings.IndexByte(initialChars, last[i])
+		if j < 0 {
+			return make([]int, numInitials)
+		}
+		slots[i] = j
+	}
+	return slots
+}
+
+func initialsName(slots []int) string {
+	name := make([]byte, len(slots))
+	for i, s := range slots {
+		name[i] = initialChars[s]
+	}
+	return string(name)
+}
+
+func (g *Game) drawInitials(slots []int, slot int) {
+	g.DrawPlay()
+	w, h := len(initialsHelp)+initialsWPad, initialsHeight
+	x, y := g.w/2-w/2, g.h/2-h/2
+	tbrect(x, y, w, h, fgInitials, bgInitials, true)
+
+	y += 2
+	tbprint(g.w/2-len(highscoreMsg)/2, y, fgInitials, bgInitials, highscoreMsg)
+	y += 2
+	tbprint(g.w/2-len(initialsPrompt)/2, y, fgInitials, bgInitials, initialsPrompt)
+
+	y += 2
+	x = g.w/2 - (numInitials-1)*initialsGap/2
+	for i, s := range slots {
+		c := string(initialChars[s])
+		if i == slot {
+			tbprint(x, y, magenta, bgInitials, "^")
+			tbprint(x, y+1, fgModeHighlight, bgModeHighlight, c)
+			tbprint(x, y+2, magenta, bgInitials, "v")
+		} else {
+			tbprint(x, y+1, neonGreen, bgInitials, c)
+		}
+		x += initialsGap
+	}
+
+	y += 4
+	tbprint(g.w/2-len(initialsHelp)/2, y, fgInitials, bgInitials, initialsHelp)
+	y++
+	tbprint(g.w/2-len(initialsTab)/2, y, fgInitials, bgInitials, initialsTab)
+
+	flush()
+}
+
+// getInitials asks for a name the arcade way, which works with just the
+// joystick. It returns false if the player pressed Tab to type one
+// instead.
+func (g *Game) getInitials() (string, bool) {
+	slots, slot := startInitials(), 0
+	g.jsHeld = make(map[termbox.Key]int)
+
+	// anything held from playing doesn't count as a press
+	g.joystickPresses()
+	for {
+		g.drawInitials(slots, slot)
+		g.Tick()
+
+		keys := g.joystickPresses()
+		select {
+		case ev := <-g.evq:
+			if ev.Type == termbox.EventKey {
+				keys = append(keys, ev.Key)
+			}
+		default:
+		}
+
+		for _, k := range keys {
+			switch k {
+			case termbox.KeyArrowUp:
+				slots[slot] = (slots[slot] + 1) % len(initialChars)
+			case termbox.KeyArrowDown:
+				slots[slot] = (slots[slot] - 1 + len(initialChars)) % len(initialChars)
+			case termbox.KeyArrowLeft:
+				slot = (slot - 1 + numInitials) % numInitials
+			case termbox.KeyArrowRight:
+				slot = (slot + 1) % numInitials
+			case termbox.KeySpace, termbox.KeyEnter:
+				return initialsName(slots), true
+			case termbox.KeyTab:
+				return "", false
+			}
+		}
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+// A level pack is a plain text file describing one or more levels. Lines
+// starting with '#' are comments. For example:
+//
+//	level The Wall
+//	speed 12
+//	fire 80
+//	formation
+//	LLLLLLLL
+//	M.M..M.M
+//	SSSSSSSS
+//	end
+//	barricade 10
+//	  xxxxx
+//	xxxxxxxxx
+//	end
+//
+// speed is the number of frames between alien steps and fire is the chance
+// (1 in fire) that an alien shoots when it steps; if left out they carry on
+// from the previous level, getting harder as usual. Each formation character
+// is an alien type (see alienKinds) or '.' for a gap, and every row is the
+// same width. Each barricade block
+// gives the column of the barricade's left edge followed by its shape;
+// barricades sit just above the player. A level without barricades keeps
+// whatever is left of the previous level's.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+type Barricade struct {
+	x     int
+	shape []string
+}
+
+type Level struct {
+	name        string
+	moveEvery   uint8
+	shootValMax int
+	formation   []string
+	barricades  []*Barricade
+
+	// where the level starts in its file, for error messages
+	line int
+}
+
+type LevelError struct {
+	file  string
+	line  int
+	level int
+	msg   string
+}
+
+func (e *LevelError) Error() string {
+	if e.level > 0 {
+		return fmt.Sprintf("%s:%d: level %d: %s", e.file, e.line, e.level, e.msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.file, e.line, e.msg)
+}
+
+type LevelErrors []*LevelError
+
+func (e LevelErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+const (
+	formationGap   = '.'
+	barricadeHit   = 'x'
+	maxMoveEvery   = 2 * fps
+	maxShootValMax = 1 << 16
+)
+
+var (
+	// loaded with --levels, nil if the formations are generated instead
+	levels     []*Level
+	levelsFile string
+)
+
+func loadLevels(filename string) ([]*Level, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseLevels(filename, data)
+}
+
+func parseLevels(filename string, data []byte) ([]*Level, error) {
+	var (
+		pack  []*Level
+		errs  LevelErrors
+		l     *Level
+		block *[]string
+		n     int
+	)
+	fail := func(format string, a ...interface{}) {
+		errs = append(errs, &LevelError{filename, n, len(pack), fmt.Sprintf(format, a...)})
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		n++
+		line := strings.TrimRight(s.Text(), " \t\r")
+
+		// inside a formation or barricade block lines are taken literally
+		if block != nil {
+			if strings.TrimSpace(line) == "end" {
+				block = nil
+			} else {
+				*block = append(*block, line)
+			}
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] != "level" && l == nil {
+			fail("%q before the first level", fields[0])
+			continue
+		}
+
+		switch fields[0] {
+		case "level":
+			l = &Level{name: strings.TrimSpace(strings.TrimPrefix(line, "level")), line: n}
+			pack = append(pack, l)
+		case "speed":
+			if i, ok := levelInt(fields, 1, maxMoveEvery); ok {
+				l.moveEvery = uint8(i)
+			} else {
+				fail("speed must be a number from 1 to %d", maxMoveEvery)
+			}
+		case "fire":
+			if i, ok := levelInt(fields, 1, maxShootValMax); ok {
+				l.shootValMax = i
+			} else {
+				fail("fire must be a number from 1 to %d", maxShootValMax)
+			}
+		case "formation":
+			if l.formation != nil {
+				fail("more than one formation")
+			}
+			l.formation = make([]string, 0)
+			block = &l.formation
+		case "barricade":
+			if i, ok := levelInt(fields, 0, 1<<16); ok {
+				b := &Barricade{i, make([]string, 0)}
+				l.barricades = append(l.barricades, b)
+				block = &b.shape
+			} else {
+				fail("barricade needs the column of its left edge")
+			}
+		default:
+			fail("unknown directive %q", fields[0])
+		}
+	}
+	if block != nil {
+		fail("missing \"end\"")
+	}
+
+	if len(pack) == 0 && len(errs) == 0 {
+		errs = append(errs, &LevelError{filename, n, 0, "no levels found"})
+	}
+	for i, l := range pack {
+		for _, msg := range l.check() {
+			errs = append(errs, &LevelError{filename, l.line, i + 1, msg})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return pack, nil
+}
+
+func formatLevels(pack []*Level) []byte {
+	var b bytes.Buffer
+	for i, l := range pack {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "level %s\n", l.name)
+		if l.moveEvery != 0 {
+			fmt.Fprintf(&b, "speed %d\n", l.moveEvery)
+		}
+		if l.shootValMax != 0 {
+			fmt.Fprintf(&b, "fire %d\n", l.shootValMax)
+		}
+
+		// trailing gaps don't change anything, but the rows have to be
+		// as wide as each other
+		formation := make([]string, len(l.formation))
+		width := 1
+		for j, row := range l.formation {
+			formation[j] = strings.TrimRight(row, string(formationGap))
+			if len(formation[j]) > width {
+				width = len(formation[j])
+			}
+		}
+		for len(formation) > 0 && formation[len(formation)-1] == "" {
+			formation = formation[:len(formation)-1]
+		}
+		b.WriteString("formation\n")
+		for _, row := range formation {
+			b.WriteString(row + strings.Repeat(string(formationGap), width-len(row)) + "\n")
+		}
+		b.WriteString("end\n")
+
+		for _, bar := range l.barricades {
+			fmt.Fprintf(&b, "barricade %d\n", bar.x)
+			for _, row := range bar.shape {
+				b.WriteString(row + "\n")
+			}
+			b.WriteString("end\n")
+		}
+	}
+	return b.Bytes()
+}
+
+func saveLevels(filename string, pack []*Level) error {
+	return ioutil.WriteFile(filename, formatLevels(pack), 0666)
+}
+
+func levelInt(fields []string, min, max int) (int, bool) {
+	if len(fields) != 2 {
+		return 0, false
+	}
+	i, err := strconv.Atoi(fields[1])
+	if err != nil || i < min || i > max {
+		return 0, false
+	}
+	return i, true
+}
+
+// check finds the mistakes that don't depend on the terminal size
+func (l *Level) check() []string {
+	msgs := make([]string, 0)
+	if len(l.formation) == 0 {
+		msgs = append(msgs, "no formation")
+	}
+	empty := true
+	for i, row := range l.formation {
+		for _, c := range row {
+			if alienKind(c) != nil {
+				empty = false
+			} else if c != formationGap {
+				msgs = append(msgs, fmt.Sprintf("formation row %d: unknown alien %q", i+1, c))
+			}
+		}
+	}
+	if len(l.formation) > 0 && empty {
+		msgs = append(msgs, "formation has no aliens")
+	}
+	for i, row := range l.formation {
+		if len(row) != len(l.formation[0]) {
+			msgs = append(msgs, fmt.Sprintf("formation row %d is %d wide but row 1 is %d",
+				i+1, len(row), len(l.formation[0])))
+		}
+	}
+
+	for i, b := range l.barricades {
+		if len(b.shape) == 0 {
+			msgs = append(msgs, fmt.Sprintf("barricade %d has no shape", i+1))
+		}
+		for _, row := range b.shape {
+			for _, c := range row {
+				if c != barricadeHit && c != ' ' {
+					msgs = append(msgs, fmt.Sprintf("barricade %d: %q should be %q or a space", i+1, c, barricadeHit))
+					break
+				}
+			}
+		}
+	}
+	return msgs
+}
+
+func (l *Level) formationWidth() int {
+	w := 0
+	for _, row := range l.formation {
+		if len(row) > w {
+			w = len(row)
+		}
+	}
+	return w
+}
+
+func (b *Barricade) width() int {
+	w := 0
+	for _, row := range b.shape {
+		if len(row) > w {
+			w = len(row)
+		}
+	}
+	return w
+}
+
+// validateLevels checks that every level fits on the terminal
+func (g *Game) validateLevels(filename string, pack []*Level) error {
+	var errs LevelErrors
+	fail := func(i int, format string, a ...interface{}) {
+		errs = append(errs, &LevelError{filename, pack[i].line, i + 1, fmt.Sprintf(format, a...)})
+	}
+
+	for i, l := range pack {
+		if w := l.formationWidth(); alienStartx+w*(alienSpriteWidth+alienPadHorizontal) > g.w {
+			fail(i, "formation is %d aliens wide but this terminal only fits %d",
+				w, (g.w-alienStartx)/(alienSpriteWidth+alienPadHorizontal))
+		}
+		if h := len(l.formation); initAlienStarty+h*(alienSpriteHeight+alienPadVertical) > g.barricadeYPos() {
+			fail(i, "formation is %d aliens tall but this terminal only fits %d",
+				h, (g.barricadeYPos()-initAlienStarty)/(alienSpriteHeight+alienPadVertical))
+		}
+
+		taken := make([]int, g.w)
+		for j, b := range l.barricades {
+			switch {
+			case b.x+b.width() > g.w:
+				fail(i, "barricade %d reaches column %d but this terminal is %d wide", j+1, b.x+b.width(), g.w)
+				continue
+			case len(b.shape) > barricadeSpriteHeight:
+				fail(i, "barricade %d is %d tall, the most is %d", j+1, len(b.shape), barricadeSpriteHeight)
+			}
+			for x := b.x; x < b.x+b.width(); x++ {
+				if taken[x] != 0 {
+					fail(i, "barricade %d overlaps barricade %d", j+1, taken[x])
+					break
+				}
+				taken[x] = j + 1
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// currentLevel returns the level being played from the loaded pack, which
+// starts again from the beginning once it runs out
+func currentLevel() *Level {
+	if len(levels) == 0 {
+		return nil
+	}
+	return levels[(lvl-1)%len(levels)]
+}
+
+// defaultFormation fills the screen as best it can
+func defaultFormation() []string {
+	formation := make([]string, 0, numRows)
+	for _, r := range []struct {
+		c    string
+		rows int
+	}{{"L", rowsLg}, {"M", rowsMd}, {"S", rowsSm}} {
+		for i := 0; i < r.rows; i++ {
+			formation = append(formation, strings.Repeat(r.c, aliensHorizontal))
+		}
+	}
+	return formation
+}
+
+func (g *Game) makeBarricades(barricades []*Barricade) [][]int {
+	screen := make([][]int, g.w)
+	for i := range screen {
+		screen[i] = make([]int, g.h)
+		for j := range screen[i] {
+			screen[i][j] = nonIndex
+		}
+	}
+
+	for i, b := range barricades {
+		for y, l := range b.shape {
+			for x, c := range l {
+				// the terminal may have shrunk since the level was checked
+				if c == barricadeHit && b.x+x < g.w && g.barricadeYPos()+y < g.h {
+					screen[b.x+x][g.barricadeYPos()+y] = i
+				}
+			}
+		}
+	}
+
+	return screen
+}
+
+// barricadeBand draws a level's barricades into a [y][x] grid as wide as the
+// terminal and as tall as a barricade
+func (g *Game) barricadeBand(l *Level) [][]bool {
+	band := make([][]bool, barricadeSpriteHeight)
+	for y := range band {
+		band[y] = make([]bool, g.w)
+	}
+	for _, b := range l.barricades {
+		for y, row := range b.shape {
+			for x, c := range row {
+				if c == barricadeHit && y < len(band) && b.x+x < g.w {
+					band[y][b.x+x] = true
+				}
+			}
+		}
+	}
+	return band
+}
+
+// bandBarricades splits a barricade band into barricades wherever there is a
+// clear column
+func bandBarricades(band [][]bool) []*Barricade {
+	barricades := make([]*Barricade, 0)
+	var b *Barricade
+	for x := 0; x <= len(band[0]); x++ {
+		clear := true
+		for y := 0; x < len(band[0]) && y < len(band); y++ {
+			if band[y][x] {
+				clear = false
+			}
+		}
+
+		switch {
+		case !clear && b == nil:
+			b = &Barricade{x, nil}
+		case clear && b != nil:
+			for y := range band {
+				row := ""
+				for i := b.x; i < x; i++ {
+					if band[y][i] {
+						row += string(barricadeHit)
+					} else {
+						row += " "
+					}
+				}
+				b.shape = append(b.shape, strings.TrimRight(row, " "))
+			}
+			for len(b.shape) > 0 && b.shape[len(b.shape)-1] == "" {
+				b.shape = b.shape[:len(b.shape)-1]
+			}
+			barricades = append(barricades, b)
+			b = nil
+		}
+	}
+	return barricades
+}
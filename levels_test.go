@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestParseLevels(t *testing.T) {
+	example, err := ioutil.ReadFile("example.levels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	barricade := "barricade 10\n  xxx\nxxxxxxx\nend\n"
+
+	for _, c := range []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"example", string(example), ""},
+		{"valid", "level One\nspeed 10\nfire 50\nformation\nLL.LL\nMMMMM\nend\n" + barricade, ""},
+		{"bad alien", "level One\nformation\nLLQLL\nend\n", `unknown alien 'Q'`},
+		{"ragged", "level One\nformation\nLLLLL\nMMM\nend\n", "row 2 is 3 wide but row 1 is 5"},
+		{"no aliens", "level One\nformation\n...\nend\n", "formation has no aliens"},
+		{"bad speed", "level One\nspeed fast\nformation\nL\nend\n", "speed must be a number"},
+		{"speed too slow", "level One\nspeed 1000\nformation\nL\nend\n", "speed must be a number"},
+		{"bad fire", "level One\nfire 0\nformation\nL\nend\n", "fire must be a number"},
+		{"bad barricade", "level One\nformation\nL\nend\nbarricade 3\nx-x\nend\n", `'-' should be 'x'`},
+		{"no end", "level One\nformation\nL\n", `missing "end"`},
+		{"nothing", "# just a comment\n", "no levels found"},
+		{"too wide", "level One\nformation\n" + strings.Repeat("L", 30) + "\nend\n",
+			"formation is 30 aliens wide"},
+		{"too tall", "level One\nformation\n" + strings.Repeat("L\n", 20) + "end\n", "formation is 20 aliens tall"},
+		{"barricade too wide", "level One\nformation\nL\nend\nbarricade 115\nxxxxxxxxxxx\nend\n",
+			"barricade 1 reaches column 126"},
+		{"barricade too tall", "level One\nformation\nL\nend\nbarricade 3\n" + strings.Repeat("x\n", 6) + "end\n",
+			"barricade 1 is 6 tall"},
+		{"barricades overlap", "level One\nformation\nL\nend\n" + barricade + barricade,
+			"barricade 2 overlaps barricade 1"},
+	} {
+		g := &Game{w: 120, h: 45}
+		pack, err := parseLevels("test.levels", []byte(c.data))
+		if err == nil {
+			err = g.validateLevels("test.levels", pack)
+		}
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%s: got error %v, want %q", c.name, err, c.err)
+		}
+	}
+}
+
+// TestFormatLevels checks that a pack saved from the editor, where rows
+// can be left short, loads again
+func TestFormatLevels(t *testing.T) {
+	pack := []*Level{{name: "One", moveEvery: 10, formation: []string{"LL", "M.M..", "", "S"},
+		barricades: []*Barricade{{10, []string{" x", "xxx"}}}}}
+	again, err := parseLevels("test.levels", formatLevels(pack))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(again[0].formation, "/"); got != "LL./M.M/.../S.." {
+		t.Errorf("formation is %s", got)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	lockRetry = 50 * time.Millisecond
+
+	// a lock file older than this was left behind by a game that crashed
+	staleLock = 10 * time.Second
+)
+
+// lockFile takes a lock on filename by creating it, waiting for anyone else
+// who has it. Without flock, a lock left by a crash is broken once it's
+// stale.
+func lockFile(filename string) (unlock func(), err error) {
+	deadline := time.Now().Add(staleLock)
+	for {
+		f, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(filename) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if fi, err := os.Stat(filename); err == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(filename)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", filename)
+		}
+		time.Sleep(lockRetry)
+	}
+}
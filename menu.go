@@ -21,6 +21,7 @@ type Star struct {
 
 const (
 	menuPad         = 10
+	minMenuPad      = 2
 	fgMenu          = red
 	bgMenu          = termbox.ColorBlack
 	fgMenuHighlight = termbox.ColorBlack
@@ -59,13 +60,18 @@ I      I  I   I   \   I      \  / /  /    \  \  I /    I   ----| I   I    \  \
 const (
 	FirstMenuItem     = 0
 	Play          int = iota - 1
+	Practice
 	Highscores
+	Replays
 	Howto
+	Editor
+	Achievements
+	Stats
 	NumMenuItems
 )
 
 var (
-	menuItems      = map[int]string{Play: "PLAY", Highscores: "HIGHSCORES", Howto: "HOWTO"}
+	menuItems      = map[int]string{Play: "PLAY", Practice: "PRACTICE", Highscores: "HIGHSCORES", Replays: "REPLAYS", Howto: "HOWTO", Editor: "EDITOR", Achievements: "ACHIEVEMENTS", Stats: "STATS"}
 	logoLines      = strings.Split(logo, "\n")
 	logoLineLength = len(logoLines[0])
 	logoHeight     = len(logoLines)
@@ -85,14 +91,19 @@ func (g *Game) DrawMenu() {
 	PrintLogo(x, y, fgMenu, bgMenu, logoLines)
 
 	length := 0
-	i := 0
 	for _, v := range menuItems {
 		length += len(v)
-		if i+1 != len(menuItems) {
-			length += menuPad
+	}
+
+	// squeeze the items together if they don't fit at the usual spacing
+	pad := menuPad
+	if gaps := len(menuItems) - 1; length+gaps*pad > g.w-2*minMenuPad {
+		pad = (g.w - 2*minMenuPad - length) / gaps
+		if pad < minMenuPad {
+			pad = minMenuPad
 		}
-		i++
 	}
+	length += (len(menuItems) - 1) * pad
 
 	x = g.w/2 - length/2
 	y += logoHeight + 5
@@ -103,7 +114,7 @@ func (g *Game) DrawMenu() {
 		} else {
 			tbprint(x, y, fgMenu, bgMenu, v)
 		}
-		x += len(v) + menuPad
+		x += len(v) + pad
 	}
 
 	for _, s := range stars {
@@ -145,10 +156,20 @@ func (g *Game) HandleKeyMenu(k termbox.Key) {
 		switch g.hmi {
 		case Highscores:
 			g.GoHighscores()
+		case Replays:
+			g.GoReplays()
 		case Howto:
 			g.GoHowto()
 		case Play:
-			g.GoPlay()
+			g.GoModes()
+		case Practice:
+			g.GoPractice()
+		case Editor:
+			g.GoEditor()
+		case Achievements:
+			g.GoAchievements()
+		case Stats:
+			g.GoStats()
 		}
 	}
 }
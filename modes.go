@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+)
+
+// GameMode is used as an enum
+type GameMode uint8
+
+const (
+	ClassicMode GameMode = iota
+	EndlessMode
+	TimeAttackMode
+	HardcoreMode
+	NumModes
+)
+
+type ModeRules struct {
+	name string
+
+	// key is what highscores are filed under
+	key   string
+	blurb string
+	lives int
+
+	// alienMoveEvery at the start of a game, and the fastest it gets
+	moveEvery    uint8
+	minMoveEvery uint8
+
+	// in frames, 0 means no time limit
+	timeLimit int
+
+	// where older versions kept this mode's highscores
+	legacyFile string
+}
+
+// Difficulty is used as an enum
+type Difficulty uint8
+
+const (
+	Easy Difficulty = iota
+	Normal
+	Hard
+	NumDifficulties
+)
+
+type DifficultyRules struct {
+	name string
+	key  string
+
+	// added to the mode's alienMoveEvery, and how much more or less often
+	// aliens shoot
+	moveEvery  int
+	shootScale float64
+}
+
+const (
+	fgModes         = neonGreen
+	bgModes         = termbox.ColorBlack
+	fgModeHighlight = termbox.ColorBlack
+	bgModeHighlight = neonGreen
+	modesTitle      = "SELECT MODE"
+	modesWPad       = 8
+	modesHPad       = 2
+
+	timeAttackLimit   = 3 * 60 * fps
+	timeAttackPenalty = 10 * fps
+	timeText          = "Time: "
+
+	// endless mode keeps escalating once the march is at full speed
+	endlessShootStep = 10
+	minShootValMax   = 20
+	endlessDropStep  = 1
+)
+
+var modes = map[GameMode]*ModeRules{
+	ClassicMode: &ModeRules{"CLASSIC", "classic", "The original. Five lives.",
+		initLives, 15, 2, 0, highscoreFilename},
+	EndlessMode: &ModeRules{"ENDLESS", "endless", "Waves never stop escalating.",
+		initLives, 15, 2, 0, highscoreFilename + "-endless"},
+	TimeAttackMode: &ModeRules{"TIME ATTACK", "timeattack", "Maximise your score in 3 minutes.",
+		0, 15, 2, timeAttackLimit, highscoreFilename + "-timeattack"},
+	HardcoreMode: &ModeRules{"HARDCORE", "hardcore", "One life. Faster aliens.",
+		1, 10, 1, 0, highscoreFilename + "-hardcore"},
+}
+
+var difficulties = map[Difficulty]*DifficultyRules{
+	Easy:   &DifficultyRules{"EASY", "easy", 5, 2},
+	Normal: &DifficultyRules{"NORMAL", "normal", 0, 1},
+	Hard:   &DifficultyRules{"HARD", "hard", -3, 0.5},
+}
+
+func (g *Game) rules() *ModeRules {
+	return modes[g.mode]
+}
+
+// modeByKey finds the mode highscores are filed under key, or NumModes if
+// there isn't one
+func modeByKey(key string) GameMode {
+	for m, r := range modes {
+		if r.key == key {
+			return m
+		}
+	}
+	return NumModes
+}
+
+func difficultyByKey(key string) Difficulty {
+	for d, r := range difficulties {
+		if r.key == key {
+			return d
+		}
+	}
+	return NumDifficulties
+}
+
+func (g *Game) diff() *DifficultyRules {
+	return difficulties[g.difficulty]
+}
+
+// startMoveEvery is how fast the aliens march at the start of a game
+func (g *Game) startMoveEvery() uint8 {
+	m := int(g.rules().moveEvery) + g.diff().moveEvery
+	if m < int(g.rules().minMoveEvery) {
+		return g.rules().minMoveEvery
+	}
+	return uint8(m)
+}
+
+// playerShot is called when an alien bullet hits the player and reports
+// whether that ended the game.
+func (g *Game) playerShot() bool {
+	switch g.mode {
+	case TimeAttackMode:
+		timeLeft -= timeAttackPenalty
+		return timeLeft <= 0
+	default:
+		player.lives -= 1
+		return player.lives == 0
+	}
+}
+
+// aliensLanded is called when the formation reaches the player and reports
+// whether that ended the game. If it didn't, the wave is restarted.
+func (g *Game) aliensLanded() bool {
+	switch {
+	case g.invulnerable():
+	case g.mode == EndlessMode:
+		player.lives -= 1
+		if player.lives == 0 {
+			return true
+		}
+	case g.mode == TimeAttackMode:
+		timeLeft -= timeAttackPenalty
+		if timeLeft <= 0 {
+			return true
+		}
+	default:
+		return true
+	}
+
+	g.WipeBullets()
+	alienv = rightMove
+	g.BeginNextLevel()
+	return false
+}
+
+// escalate makes the next wave harder
+func (g *Game) escalate() {
+	if alienMoveEvery > g.rules().minMoveEvery {
+		alienMoveEvery--
+		return
+	}
+
+	if g.mode == EndlessMode {
+		if alienShootValMax > minShootValMax {
+			alienShootValMax -= endlessShootStep
+		}
+		if alienStarty+endlessDropStep+numRows*(alienSpriteHeight+alienPadVertical) <
+			g.barricadeYPos()-alienSpriteHeight {
+			alienStarty += endlessDropStep
+		}
+	}
+}
+
+// resetEscalation puts the aliens' speed, fire rate and starting height
+// back to how they are on the first level
+func (g *Game) resetEscalation() {
+	alienStarty = initAlienStarty
+	alienMoveEvery = g.startMoveEvery()
+	alienShootValMax = int(float64(initAlienShootValMax) * g.diff().shootScale)
+}
+
+func (g *Game) gameOverText() string {
+	if g.mode == TimeAttackMode && timeLeft <= 0 {
+		return "TIME UP"
+	}
+	return "GAME OVER"
+}
+
+func timeLeftText() string {
+	if timeLeft < 0 {
+		return timeText + "0:00"
+	}
+	secs := timeLeft / fps
+	return timeText + fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+func (g *Game) DrawModes() {
+	g.DrawMenu()
+
+	w := len(modesTitle)
+	for m := ClassicMode; m < NumModes; m++ {
+		if l := len(modes[m].blurb); l > w {
+			w = l
+		}
+	}
+	w += modesWPad
+	h := 6 + int(NumModes)*3 + modesHPad
+	x, y := g.w/2-w/2, logoY
+	tbrect(x, y, w, h, fgModes, bgModes, true)
+
+	y += modesHPad / 2
+	tbprint(g.w/2-len(modesTitle)/2, y, fgModes, bgModes, modesTitle)
+	y += 2
+
+	for m := ClassicMode; m < NumModes; m++ {
+		r := modes[m]
+		name := " " + r.name + " "
+		if m == g.mode {
+			tbprint(g.w/2-len(name)/2, y, fgModeHighlight, bgModeHighlight, name)
+		} else {
+			tbprint(g.w/2-len(name)/2, y, fgModes, bgModes, name)
+		}
+		y++
+		tbprint(g.w/2-len(r.blurb)/2, y, fgModes, bgModes, r.blurb)
+		y += 2
+	}
+
+	diff := "Difficulty: < " + g.diff().name + " > "
+	x = g.w/2 - len(diff+"(Left/Right)")/2
+	tbprint(x, y, fgModes, bgModes, diff)
+	tbprint(x+len(diff), y, magenta, bgModes, "(Left/Right)")
+	y++
+
+	dives := "Dive attacks: OFF "
+	if g.dives {
+		dives = "Dive attacks: ON "
+	}
+	x = g.w/2 - len(dives+"(Tab)")/2
+	tbprint(x, y, fgModes, bgModes, dives)
+	tbprint(x+len(dives), y, magenta, bgModes, "(Tab)")
+	y += 2
+
+	tbprintPrompt(y, "Press ", "Enter ", "to pick a ship, ", "ESC ", "to go back")
+}
+
+func (g *Game) UpdateModes() {
+	g.UpdateMenu()
+}
+
+func (g *Game) HandleKeyModes(k termbox.Key) {
+	switch k {
+	case termbox.KeyArrowUp:
+		g.mode = (g.mode - 1 + NumModes) % NumModes
+	case termbox.KeyArrowDown:
+		g.mode = (g.mode + 1) % NumModes
+	case termbox.KeyArrowLeft:
+		g.difficulty = (g.difficulty - 1 + NumDifficulties) % NumDifficulties
+	case termbox.KeyArrowRight:
+		g.difficulty = (g.difficulty + 1) % NumDifficulties
+	case termbox.KeyTab:
+		g.dives = !g.dives
+	case termbox.KeyEsc:
+		g.GoMenu()
+	case termbox.KeyEnter:
+		fallthrough
+	case termbox.KeySpace:
+		g.GoShips()
+	}
+}
+
+func (g *Game) GoModes() {
+	g.state = ModesState
+	g.cfg = fgMenu
+	g.cbg = bgMenu
+}
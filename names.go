@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// names are measured in columns on the screen, so wide characters
+	// count twice
+	minNameLen = 3
+	maxNameLen = 10
+
+	lastNameFilename = "name"
+)
+
+func nameWidth(name string) int {
+	return runewidth.StringWidth(name)
+}
+
+// nameRune reports whether r can go in a name. The separator can't, as
+// the older highscore files and the achievements file use it.
+func nameRune(r rune) bool {
+	return unicode.IsPrint(r) && r != utf8.RuneError && !strings.ContainsRune(highscoreSeparator, r)
+}
+
+func validName(name string) bool {
+	if !utf8.ValidString(name) {
+		return false
+	}
+	for _, r := range name {
+		if !nameRune(r) {
+			return false
+		}
+	}
+	w := nameWidth(name)
+	return w >= minNameLen && w <= maxNameLen
+}
+
+// padName pads name with spaces to maxNameLen columns, which fmt can't do
+// as it counts runes
+func padName(name string) string {
+	if w := nameWidth(name); w < maxNameLen {
+		return name + strings.Repeat(" ", maxNameLen-w)
+	}
+	return name
+}
+
+// loadLastName returns the name last entered for a highscore, or nothing
+// if there isn't one
+func loadLastName() string {
+	data, err := ioutil.ReadFile(configPath(lastNameFilename))
+	if err != nil {
+		return ""
+	}
+	name := strings.TrimSpace(string(data))
+	if !validName(name) {
+		return ""
+	}
+	return name
+}
+
+func saveLastName(name string) error {
+	if err := os.MkdirAll(dirs.config, dataDirPerm); err != nil {
+		return err
+	}
+	return writeFileAtomic(configPath(lastNameFilename), []byte(name+"\n"), 0666)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Dirs are where the game keeps its files, following the XDG base directory
+// spec: highscores, achievements and stats are data, the log is state, and
+// settings are config.
+type Dirs struct {
+	data   string
+	config string
+	state  string
+}
+
+const (
+	appDirName   = "spaceinvaders"
+	logFilename  = "diwe.log"
+	dataDirPerm  = 0755
+	xdgDataHome  = "XDG_DATA_HOME"
+	xdgConfig    = "XDG_CONFIG_HOME"
+	xdgStateHome = "XDG_STATE_HOME"
+)
+
+var dirs Dirs
+
+// xdgDir returns $env/spaceinvaders, or fallback under the home directory
+// if env isn't set. The spec says relative paths in env should be ignored.
+func xdgDir(env string, fallback ...string) (string, error) {
+	if d := os.Getenv(env); filepath.IsAbs(d) {
+		return filepath.Join(d, appDirName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("can't find a place to keep files, set $%s or $HOME: %v", env, err)
+	}
+	return filepath.Join(append(append([]string{home}, fallback...), appDirName)...), nil
+}
+
+// resolveDirs works out the directories, using the ones given on the
+// command line where there are any, and makes sure the data and state
+// directories exist. The config directory is made when something is saved
+// there.
+func resolveDirs(data, config, state string) error {
+	for _, d := range []struct {
+		dir      *string
+		flag     string
+		env      string
+		fallback []string
+	}{
+		{&dirs.data, data, xdgDataHome, []string{".local", "share"}},
+		{&dirs.config, config, xdgConfig, []string{".config"}},
+		{&dirs.state, state, xdgStateHome, []string{".local", "state"}},
+	} {
+		if d.flag != "" {
+			*d.dir = d.flag
+			continue
+		}
+		dir, err := xdgDir(d.env, d.fallback...)
+		if err != nil {
+			return err
+		}
+		*d.dir = dir
+	}
+
+	for _, d := range []string{dirs.data, dirs.state} {
+		if err := os.MkdirAll(d, dataDirPerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dataPath(name string) string {
+	return filepath.Join(dirs.data, name)
+}
+
+func configPath(name string) string {
+	return filepath.Join(dirs.config, name)
+}
+
+func statePath(name string) string {
+	return filepath.Join(dirs.state, name)
+}
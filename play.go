@@ -2,9 +2,9 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
+	"log"
+	"math"
 	"math/rand"
-	"sort"
 	"strings"
 	"time"
 
@@ -28,6 +28,9 @@ const (
 	scoreText      = "Score: "
 	scorex, scorey = 10, 1
 
+	// practice and console notices
+	hudy = scorey + 1
+
 	livesText        = "Lives: "
 	livesRightOffset = 0
 
@@ -35,11 +38,11 @@ const (
 	ufoIndex     = 666
 	ufoReward    = 100
 
-	alienBulletSpeed   = 1
-	alienShootValMax   = 100
-	alienShootVal      = 1
-	alienPadVertical   = 1
-	alienPadHorizontal = 3
+	alienBulletSpeed     = 1
+	initAlienShootValMax = 100
+	alienShootVal        = 1
+	alienPadVertical     = 1
+	alienPadHorizontal   = 3
 
 	// rwd is reward
 	rwdSm  = 10
@@ -50,7 +53,7 @@ const (
 	fragmentLifetime = fps
 	numFragments     = 4
 
-	alienStartx, alienStarty = 10, 7
+	alienStartx, initAlienStarty = 10, 7
 
 	numBarricades = 4
 
@@ -83,25 +86,39 @@ type FragmentGroup struct {
 type Bullet struct {
 	RegEntity
 	vy int
+
+	// aimed bullets drift sideways
+	fx, vx float64
 }
 
 type Player struct {
 	RegEntity
 	score, lives int
-	bullet       *Bullet
+	bullets      []*Bullet
+	ship         *Ship
+	shield       int
 }
 
 type Alien struct {
 	AnimatedEntity
 	reward int
+	kind   *AlienKind
+	hp     int
+
+	// frames left showing that it's been hit
+	flash int
+
+	// formation slot, which the alien leaves while it dives
+	home Point
+	dive *Dive
 }
 
 func NewBullet(x, y, vy int) *Bullet {
-	return &Bullet{RegEntity{Entity{x, y, fgBullet, bgBullet}, bulletSprite}, vy}
+	return &Bullet{RegEntity{Entity{x, y, fgBullet, bgBullet}, bulletSprite}, vy, float64(x), 0}
 }
 
-func NewAlien(x, y int, fg, bg termbox.Attribute, sprite [2]string, reward int) *Alien {
-	return &Alien{AnimatedEntity{Entity{x, y, fg, bg}, sprite}, reward}
+func NewAlien(x, y int, k *AlienKind) *Alien {
+	return &Alien{AnimatedEntity{Entity{x, y, k.fg, bgAlien}, k.sprite}, k.reward, k, k.hp, 0, Point{x, y}, nil}
 }
 
 var (
@@ -116,14 +133,18 @@ var (
 	leftMove  = [2]int{-1, 0}
 	downMove  = [2]int{0, 1}
 
+	// ufoTimer is the tick the next UFO turns up on, 0 if there isn't one
+	// coming
 	ufo      *RegEntity
-	ufoTimer <-chan time.Time
+	ufoTimer int
 
 	aliens           []*Alien
 	alienBullets     []*Bullet
 	alienSpriteIndex int
 	alienMoveEvery   uint8
 	alienv           [2]int
+	alienShootValMax int
+	alienStarty      int
 	rowsSm           = 2
 	rowsMd           = 2
 	rowsLg           = 1
@@ -134,10 +155,29 @@ var (
 	barricadePositions [][]int
 
 	lvl int
+
+	// updates since the game started
+	ticks int
+
+	// everything random in a game comes from rng, so the same seed plays
+	// out the same way
+	seed int64
+	rng  *rand.Rand
+
+	// updates per frame, see simSteps
+	simSpeed = 1.0
+	simClock float64
+
+	// frames left in a timed game
+	timeLeft int
 )
 
 func (g *Game) DrawPlay() {
-	tbprintsprite(player.x, player.y, player.fg, player.bg, player.sprite)
+	fg := player.fg
+	if player.shield > 0 {
+		fg = fgShipShielded
+	}
+	tbprintsprite(player.x, player.y, fg, player.bg, player.sprite)
 
 	for i := range barricadePositions {
 		for j := range barricadePositions[i] {
@@ -159,27 +199,47 @@ func (g *Game) DrawPlay() {
 
 	for _, a := range aliens {
 		if a != nil {
-			tbprintsprite(a.x, a.y, a.fg, a.bg, a.sprite[alienSpriteIndex])
+			fg := a.fg
+			if a.flash > 0 {
+				fg = fgAlienFlash
+			}
+			tbprintsprite(a.x, a.y, fg, a.bg, a.sprite[alienSpriteIndex])
 		}
 	}
 
-	if player.bullet != nil {
-		tbprintsprite(player.bullet.x, player.bullet.y,
-			player.bullet.fg, player.bullet.bg, player.bullet.sprite)
+	for _, b := range player.bullets {
+		if b != nil {
+			tbprintsprite(b.x, b.y, b.fg, b.bg, b.sprite)
+		}
 	}
 
 	tbprint(scorex, scorey, fgPlayText, bgPlayText, scoreText+fmt.Sprintf("%d", player.score))
 
 	livesStr := livesText + strings.Replace(strings.Repeat(livesSprite, player.lives), "⏣ ", "⏣  ", -1)
+	if g.rules().timeLimit > 0 {
+		livesStr = timeLeftText()
+	}
+	if player.shield > 0 {
+		livesStr = shieldText + strings.Repeat("+", player.shield) + "   " + livesStr
+	}
 	livesx = g.w - livesRightOffset - len(livesStr)
 	livesy = scorey
 	tbprint(livesx, livesy, fgPlayText, bgPlayText, livesStr)
 
+	switch {
+	case g.practice:
+		tbprint(g.w/2-len(practiceHUD)/2, hudy, magenta, bgPlayText, practiceHUD)
+	case g.cheated:
+		tbprint(g.w/2-len(consoleHUD)/2, hudy, magenta, bgPlayText, consoleHUD)
+	}
+
 	for i := range fragments {
 		for _, pos := range fragments[i].positions {
 			tbprint(pos[0], pos[1], fragments[i].fg, fragments[i].bg, fragments[i].sprite)
 		}
 	}
+
+	g.drawToast()
 }
 
 func (g *Game) PlayerPositions() [][]int {
@@ -193,10 +253,10 @@ func (g *Game) PlayerPositions() [][]int {
 
 	x, y := player.x, player.y
 	initx := x
-	lines := strings.Split(player.sprite, "\n")
+	lines := strings.Split(player.ship.hitbox, "\n")
 	for _, l := range lines {
 		for _, c := range l {
-			if c != ' ' {
+			if c != ' ' && x < g.w {
 				screen[x][y] = playerSpriteHere
 			}
 
@@ -242,7 +302,8 @@ func (g *Game) AlienPositions() [][]int {
 			lines := strings.Split(a.sprite[alienSpriteIndex], "\n")
 			for _, l := range lines {
 				for _, c := range l {
-					if c != ' ' {
+					// divers can be partly off the screen
+					if c != ' ' && x >= 0 && x < g.w && y >= 0 && y < g.h {
 						screen[x][y] = i
 					}
 
@@ -258,7 +319,7 @@ func (g *Game) AlienPositions() [][]int {
 }
 
 func (g *Game) WipeBullets() {
-	player.bullet = nil
+	player.bullets = make([]*Bullet, player.ship.maxBullets)
 	alienBullets = make([]*Bullet, int(aliensHorizontal*numRows/10))
 }
 
@@ -298,23 +359,34 @@ func (g *Game) genBarricades() [][]int {
 	return screen
 }
 
-func (g *Game) wipePlay() {
-	// calculate rows/cols
+// formationSize is how many columns and rows of aliens fit on the screen
+func (g *Game) formationSize() (cols, rows int) {
 	i := 0
 	for x := 0; x < (g.w / 2); x, i = x+(alienSpriteWidth+alienPadHorizontal), i+1 {
-		aliensHorizontal = i
+		cols = i
 	}
 	i = 0
-	for y := alienStarty; y < (g.barricadeYPos() - alienSpriteHeight - alienPadVertical); y, i = y+(alienSpriteHeight+alienPadVertical), i+1 {
+	for y := initAlienStarty; y < (g.barricadeYPos() - alienSpriteHeight - alienPadVertical); y, i = y+(alienSpriteHeight+alienPadVertical), i+1 {
 		if i == 5 {
 			break
 		}
 	}
 	switch {
 	case i <= 3:
+		return cols, 3
+	case i == 4:
+		return cols, 4
+	}
+	return cols, 5
+}
+
+func (g *Game) wipePlay() {
+	aliensHorizontal, numRows = g.formationSize()
+	switch numRows {
+	case 3:
 		rowsSm = 1
 		rowsMd = 1
-	case i == 4:
+	case 4:
 		rowsSm = 2
 		rowsMd = 1
 	default:
@@ -327,19 +399,35 @@ func (g *Game) wipePlay() {
 	aliens = make([]*Alien, aliensHorizontal*numRows)
 	alienBullets = make([]*Bullet, int(aliensHorizontal*numRows/10))
 	alienSpriteIndex = 0
-	alienMoveEvery = uint8(15)
+	g.resetEscalation()
 	alienv = rightMove
+	timeLeft = g.rules().timeLimit
 
 	barricadePositions = g.genBarricades()
 
 	lvl = 1
+	ticks = 0
+	if g.seed != 0 {
+		reseed(g.seed)
+	} else {
+		reseed(time.Now().UnixNano())
+	}
+	ufo, ufoTimer = nil, 0
+	recording = nil
+	simSpeed, simClock = 1, 0
+	tally = newTally()
+	toasts, toastLife = nil, 0
 }
 
-func (g *Game) drawGetName(name string, showLenWarn bool) {
+const (
+	highscoreMsg = "You set a new highscore!"
+	lenWarn      = "Name must be 3-10 characters long!"
+	sepWarn      = "Names can't have " + highscoreSeparator + " in them!"
+)
+
+func (g *Game) drawGetName(name string, warn string) {
 	const (
-		msg                  = "You set a new highscore!"
 		prompt               = "Please enter a name 3-10 characters long:"
-		lenWarn              = "Name must be 3-10 characters long!"
 		getNameHeight        = 8
 		getNameHeightLenWarn = 11
 		getNameWidthPad      = 4
@@ -348,11 +436,11 @@ func (g *Game) drawGetName(name string, showLenWarn bool) {
 		fgGetNameName        = neonGreen
 	)
 	g.DrawPlay()
-	if len(name) < 10 {
+	if nameWidth(name) < maxNameLen {
 		name += "_"
 	}
 	w, h := len(prompt)+getNameWidthPad, getNameHeight
-	if showLenWarn {
+	if warn != "" {
 		h = getNameHeightLenWarn
 	}
 	x, y := g.w/2-w/2, g.h/2-h/2
@@ -361,32 +449,52 @@ func (g *Game) drawGetName(name string, showLenWarn bool) {
 	// prompt
 	x += getNameWidthPad/2 + 1
 	y += 2
-	tbprint(x, y, fgGetName, bgGetName, msg)
+	tbprint(x, y, fgGetName, bgGetName, highscoreMsg)
 	y += 2
 	tbprint(x, y, fgGetName, bgGetName, prompt)
 
 	// name
-	x = g.w/2 - len(name)/2
+	x = g.w/2 - nameWidth(name)/2
 	y += 2
 	tbprint(x, y, fgGetNameName, bgGetName, name)
 
-	// showLenWarn ?
-	if showLenWarn {
-		x = g.w/2 - len(lenWarn)/2
+	if warn != "" {
+		x = g.w/2 - len(warn)/2
 		y += 3
-		tbprint(x, y, fgGetName, bgGetName, lenWarn)
+		tbprint(x, y, fgGetName, bgGetName, warn)
 	}
 
-	termbox.Flush()
+	flush()
 }
 
-func (g *Game) getName() string {
-	name := ""
-	showLenWarn := false
+// enterName asks for the name to go with a highscore, as initials if
+// there's a joystick, and switches between the two ways when Tab is pressed
+func (g *Game) enterName() string {
+	// keys pressed while playing aren't meant for the name
+	g.drainEvents()
+	initials := g.js != nil
 	for {
-		g.drawGetName(name, showLenWarn)
-		if len(name) >= 10 {
-			showLenWarn = true
+		get := g.getName
+		if initials {
+			get = g.getInitials
+		}
+		if name, ok := get(); ok {
+			return name
+		}
+		initials = !initials
+	}
+}
+
+// getName asks for a name, starting with the last one entered. It works in
+// runes, so backspace doesn't leave half a character behind. It returns
+// false if the player pressed Tab to enter initials instead.
+func (g *Game) getName() (string, bool) {
+	name := []rune(loadLastName())
+	warn := ""
+	for {
+		g.drawGetName(string(name), warn)
+		if nameWidth(string(name)) >= maxNameLen {
+			warn = lenWarn
 		}
 
 		select {
@@ -395,14 +503,19 @@ func (g *Game) getName() string {
 			case termbox.EventKey:
 				switch ev.Key {
 				case termbox.KeyEnter:
-					if len(name) < 3 {
-						showLenWarn = true
+					if nameWidth(string(name)) < minNameLen {
+						warn = lenWarn
 					} else {
-						return name
+						return string(name), true
 					}
+				case termbox.KeyTab:
+					return "", false
 				case 0:
-					if len(name) < 10 {
-						name += string(ev.Ch)
+					switch {
+					case !nameRune(ev.Ch):
+						warn = sepWarn
+					case nameWidth(string(append(name, ev.Ch))) <= maxNameLen:
+						name, warn = append(name, ev.Ch), ""
 					}
 				case termbox.KeyBackspace:
 					fallthrough
@@ -418,37 +531,96 @@ func (g *Game) getName() string {
 	}
 }
 
-func (g *Game) checkHighscores() {
-	if len(g.highscores) < maxHighscores || player.score > g.highscores[len(g.highscores)-1].score {
-		name := g.getName()
-		g.highscores = append(g.highscores, &Highscore{player.score, name})
-		sort.Sort(sort.Reverse(ByScore(g.highscores)))
-		if len(g.highscores) > maxHighscores {
-			g.highscores = append([]*Highscore(nil), g.highscores[:maxHighscores]...)
-		}
+// finishedGame describes the game that's just ended, for a highscore or
+// replay file
+func (g *Game) finishedGame(name string) *Highscore {
+	return &Highscore{
+		Name:       name,
+		Score:      player.score,
+		Date:       time.Now(),
+		Level:      lvl,
+		Duration:   tally.frames / fps,
+		Difficulty: g.diff().key,
+		Seed:       seed,
+		Mode:       g.rules().key,
+		Size:       sizeClasses[g.sizeClass()].key,
+		Ship:       player.ship.name,
+		Dives:      g.dives,
+		Levels:     packName(),
+		User:       currentUser(),
+		Replay:     g.newReplay(),
+	}
+}
 
-		// write highscores
-		data := ""
-		for i, score := range g.highscores {
-			data += fmt.Sprintf("%s%s%d", score.name, highscoreSeparator, score.score)
-			if i != len(g.highscores)-1 {
-				data += "\n"
-			}
-		}
-		ioutil.WriteFile(highscoreFilename, []byte(data), 0666)
+// checkHighscores asks for a name if the finished game h set a highscore,
+// and saves it
+func (g *Game) checkHighscores(h *Highscore) {
+	if !g.qualifies(g.currentBoard(), h.Score) {
+		return
+	}
+
+	h.Name = g.enterName()
+	if err := saveLastName(h.Name); err != nil {
+		log.Println(err)
+	}
+	g.addHighscore(h)
+	g.lastHighscore = h.key()
+	g.highscoresSaveErr = g.saveHighscores()
+	if g.highscoresSaveErr != nil {
+		log.Println(g.highscoresSaveErr)
+		g.FreezeFlash(saveFailedText)
+	}
+	if serverURL != "" {
+		submitGlobal(h)
 	}
 }
 
 func (g *Game) gameOver() {
-	g.FreezeFlash("GAME OVER")
-	g.checkHighscores()
+	g.checkAchievements()
+	g.FreezeFlash(g.gameOverText())
+	if g.headless {
+		// leave the score for verifyHighscore
+		g.state = MenuState
+		return
+	}
+	if g.preview {
+		g.endPreview()
+		return
+	}
+	if g.practice {
+		g.endPractice()
+		return
+	}
+	g.recordStats()
+	if g.onRecord() {
+		// the highscore and its replay are the same game, down to the date
+		h := g.finishedGame("")
+		g.checkHighscores(h)
+		if err := saveReplay(h); err != nil {
+			log.Println(err)
+		}
+	}
 	g.wipePlay()
 	g.GoMenu()
 	// TODO: finish this function, need to add highscore stuff
 }
 
-func newUfoTimer() <-chan time.Time {
-	return time.After(time.Duration(rand.Intn(20)+15) * time.Second)
+func reseed(s int64) {
+	seed = s
+	rng = rand.New(rand.NewSource(s))
+}
+
+// simSteps returns how many updates to do this frame, which is one unless
+// the game has been sped up or slowed down
+func simSteps() int {
+	simClock += simSpeed
+	n := int(simClock)
+	simClock -= float64(n)
+	return n
+}
+
+func newUfoTimer() int {
+	return ticks + (rng.Intn(20)+15)*fps
 }
 
 func newUfo() *RegEntity {
@@ -459,26 +631,39 @@ func (g *Game) explode(x, y int) {
 	fragments = append(fragments, &FragmentGroup{RegEntity{Entity{x, y, fgBullet, bgBullet}, "*"}, 0, make([][2]int, numFragments)})
 }
 
+// hitPlayer handles the player being shot or rammed
+func (g *Game) hitPlayer(cause string) {
+	tally.lostLife(cause)
+	if g.playerShot() {
+		g.gameOver()
+		return
+	}
+	g.WipeBullets()
+	g.FreezeFlash(lvlFlash())
+}
+
 func (g *Game) UpdatePlay() {
+	ticks++
+	tally.frames++
 	playerPos := g.PlayerPositions()
 	for b := range alienBullets {
 		if alienBullets[b] != nil {
-			alienBullets[b].y += alienBulletSpeed
+			alienBullets[b].y += alienBullets[b].vy
+			alienBullets[b].fx += alienBullets[b].vx
+			alienBullets[b].x = int(math.Round(alienBullets[b].fx))
 
-			if alienBullets[b].y >= g.h {
+			if alienBullets[b].y >= g.h || alienBullets[b].x < 0 || alienBullets[b].x >= g.w {
 				alienBullets[b] = nil
 			} else {
 				x, y := alienBullets[b].x, alienBullets[b].y
 				if playerPos[x][y] != nonIndex {
-					player.lives -= 1
-					if player.lives == 0 {
-						g.gameOver()
-						return
+					if g.absorbHit() {
+						alienBullets[b] = nil
+						continue
 					}
-					g.WipeBullets()
 					// TODO: clear the UFO if it's there
 					// g.ClearUFO()
-					g.FreezeFlash(lvlFlash())
+					g.hitPlayer(deathShot)
 					return
 				} else if barricadePositions[x][y] != nonIndex {
 					alienBullets[b] = nil
@@ -488,33 +673,43 @@ func (g *Game) UpdatePlay() {
 		}
 	}
 
-	if player.bullet != nil {
-		player.bullet.y += player.bullet.vy
-		if player.bullet.y < 0 {
-			player.bullet = nil
+	if timeLeft > 0 {
+		timeLeft--
+		if timeLeft == 0 {
+			g.gameOver()
+			return
+		}
+	}
+
+	for i, b := range player.bullets {
+		if b == nil {
+			continue
+		}
+		b.y += b.vy
+		if b.y < 0 {
+			player.bullets[i] = nil
 		} else {
 			screen := g.AlienPositions()
-			x, y := player.bullet.x, player.bullet.y
+			x, y := b.x, b.y
 			if screen[x][y] != nonIndex {
-				player.bullet = nil
-				g.explode(x, y)
+				player.bullets[i] = nil
+				tally.hits++
 				if screen[x][y] == ufoIndex {
+					g.explode(x, y)
 					player.score += ufoReward
 					ufo = nil
+					tally.ufoHits++
 				} else {
-					player.score += aliens[screen[x][y]].reward
-					aliens[screen[x][y]] = nil
+					g.hitAlien(screen[x][y], x, y)
 				}
 			} else if barricadePositions[x][y] != nonIndex {
-				player.bullet = nil
+				player.bullets[i] = nil
 				barricadePositions[x][y] = nonIndex
 			}
 		}
 	}
 
-	rand.Seed(time.Now().UnixNano())
-
-	if ufo != nil && g.fc%ufoMoveEvery == 0 {
+	if ufo != nil && ticks%ufoMoveEvery == 0 {
 		ufo.x += 1
 		if ufo.x > g.w {
 			ufo = nil
@@ -522,53 +717,58 @@ func (g *Game) UpdatePlay() {
 		}
 	}
 
-	if g.fc%alienMoveEvery == 0 {
+	if ticks%int(alienMoveEvery) == 0 {
 		alienSpriteIndex = (alienSpriteIndex + 1) % 2
 
 		downFlag := false
 		xval := 999999999 // some meaningless number
 		levelComplete := true
 		for i := 0; i < len(aliens); i++ {
-			if aliens[i] != nil {
+			if a := aliens[i]; a != nil {
 				levelComplete = false
-				aliens[i].x += alienv[0]
-				aliens[i].y += alienv[1]
+				a.home.x += alienv[0]
+				a.home.y += alienv[1]
+				if a.dive == nil {
+					a.x, a.y = a.home.x, a.home.y
+				}
 
-				if aliens[i].x <= 0 || aliens[i].x+alienSpriteWidth >= g.w {
+				if a.home.x <= 0 || a.home.x+alienSpriteWidth >= g.w {
 					downFlag = true
-					xval = aliens[i].x
+					xval = a.home.x
 				}
 
-				if aliens[i].y >= player.y-playerSpriteHeight {
-					g.gameOver()
+				if a.home.y >= player.y-playerSpriteHeight {
+					tally.lostLife(deathInvaded)
+					if g.aliensLanded() {
+						g.gameOver()
+					}
 					return
 				}
 
-				// try firing
-				if rand.Intn(alienShootValMax) == 6 {
-					for j := range alienBullets {
-						if alienBullets[j] == nil {
-							alienBullets[j] = NewBullet(aliens[i].x+alienSpriteWidth/2,
-								aliens[i].y, alienBulletSpeed)
-							break
-						}
-					}
+				// try firing, divers do their own
+				if a.dive == nil && rng.Intn(alienShootValMax) == 6 {
+					g.alienFire(a)
 				}
 			}
 		}
 
 		if levelComplete && ufo == nil {
-			lvl += 1
-			if alienMoveEvery != 2 {
-				alienMoveEvery--
+			if g.preview {
+				g.FreezeFlash("LEVEL CLEARED")
+				g.endPreview()
+				return
 			}
+			tally.waveCleared()
+			lvl += 1
+			g.checkAchievements()
+			g.escalate()
 			g.BeginNextLevel()
 			g.WipeBullets()
 		}
 
 		switch {
 		case alienv == downMove:
-			if xval == 0 {
+			if xval <= 0 {
 				alienv = rightMove
 			} else {
 				alienv = leftMove
@@ -578,6 +778,17 @@ func (g *Game) UpdatePlay() {
 		}
 	}
 
+	for _, a := range aliens {
+		if a != nil && a.flash > 0 {
+			a.flash--
+		}
+	}
+
+	if g.dives && g.updateDives(playerPos) && !g.absorbHit() {
+		g.hitPlayer(deathRammed)
+		return
+	}
+
 	// update any fragments
 	fragmentsCopy := make([]*FragmentGroup, 0)
 	for i := range fragments {
@@ -587,8 +798,8 @@ func (g *Game) UpdatePlay() {
 
 		if fragments[i].life%3 == 0 {
 			for j := 0; j < numFragments; j++ {
-				x := rand.Intn(8) + fragments[i].x - 3
-				y := rand.Intn(4) + fragments[i].y - 2
+				x := rng.Intn(8) + fragments[i].x - 3
+				y := rng.Intn(4) + fragments[i].y - 2
 				fragments[i].positions[j][0] = x
 				fragments[i].positions[j][1] = y
 			}
@@ -602,81 +813,141 @@ func (g *Game) UpdatePlay() {
 		fragments = fragments[:len(fragments)]
 	}
 
-	select {
-	case <-ufoTimer:
+	g.checkAchievements()
+	updateToasts()
+
+	switch {
+	case ufoTimer != 0 && ticks >= ufoTimer:
 		ufo = newUfo()
-		ufoTimer = nil
-	default:
-		if ufoTimer == nil && ufo == nil {
-			ufoTimer = newUfoTimer()
-		}
+		ufoTimer = 0
+	case ufoTimer == 0 && ufo == nil:
+		ufoTimer = newUfoTimer()
 	}
 }
 
 func (g *Game) HandleKeyPlay(k termbox.Key) {
+	recordInput(k)
 	switch k {
 	case termbox.KeyArrowRight:
-		player.x += playerMoveSpeed
+		player.x += player.ship.speed
 	case termbox.KeyArrowLeft:
-		player.x -= playerMoveSpeed
-	case termbox.KeySpace:
-		if player.bullet == nil {
-			player.bullet = NewBullet(player.x+playerSpriteWidth/2, player.y,
-				playerBulletSpeed)
+		player.x -= player.ship.speed
+	case termbox.KeyEsc:
+		if g.preview {
+			g.endPreview()
+			return
 		}
+		if g.practice {
+			g.endPractice()
+			return
+		}
+	case termbox.KeySpace:
+		playerFire()
 	}
 
 	switch {
-	case player.x+playerSpriteWidth > g.w:
-		player.x = g.w - playerSpriteWidth
+	case player.x+player.ship.width > g.w:
+		player.x = g.w - player.ship.width
 	case player.x < 0:
 		player.x = 0
 	}
 }
 
-func makeAliens(x, y, rows, cols, spriteW, spriteH, reward, arrayOffset int,
-	fg, bg termbox.Attribute, sprite [2]string) (int, int) {
-	startx := x
+func (g *Game) HandleCharPlay(ch rune) {
+	switch ch {
+	case consoleKey:
+		g.toggleConsole()
+	case 'r':
+		if g.practice {
+			g.restartWave()
+		}
+	}
+}
 
-	// create aliens
-	for i := 0; i < rows; i++ {
-		for j := 0; j < cols; j++ {
-			aliens[arrayOffset+i*aliensHorizontal+j] = NewAlien(x, y, fg, bg, sprite, reward)
-			x += spriteW + alienPadHorizontal
+func (g *Game) makeFormation(formation []string) {
+	aliensHorizontal, numRows = 0, len(formation)
+	for _, row := range formation {
+		if len(row) > aliensHorizontal {
+			aliensHorizontal = len(row)
 		}
-		x = startx
-		y += spriteH + alienPadVertical
 	}
+	aliens = make([]*Alien, aliensHorizontal*numRows)
 
-	return y, arrayOffset + rows*cols
+	// create aliens
+	y := alienStarty
+	for i, row := range formation {
+		x := alienStartx
+		for j, c := range row {
+			if k := alienKind(c); k != nil {
+				aliens[i*aliensHorizontal+j] = NewAlien(x, y, k)
+			}
+			x += alienSpriteWidth + alienPadHorizontal
+		}
+		y += alienSpriteHeight + alienPadVertical
+	}
 }
 
 func lvlFlash() string {
+	if l := currentLevel(); l != nil && l.name != "" {
+		return fmt.Sprintf("Level %d: %s", lvl, l.name)
+	}
 	return fmt.Sprintf("Level %d", lvl)
 }
 
 func (g *Game) FreezeFlash(m string) {
+	if g.headless {
+		return
+	}
 	g.Draw()
 
 	tbprint(g.w/2-len(m)/2, g.h/2, fgPlayText, bgPlayText, m)
-	termbox.Flush()
+	flush()
 
 	time.Sleep(flashDuration)
 }
 
 func (g *Game) BeginNextLevel() {
 	g.FreezeFlash(lvlFlash())
+	g.setupWave()
+}
+
+// setupWave lays out the formation for the current level
+func (g *Game) setupWave() {
+	player.shield = player.ship.shield
+
+	if l := currentLevel(); l == nil {
+		g.makeFormation(mixFormation(defaultFormation()))
+	} else {
+		if l.moveEvery != 0 {
+			alienMoveEvery = l.moveEvery
+		}
+		if l.shootValMax != 0 {
+			alienShootValMax = l.shootValMax
+		}
+		if len(l.barricades) > 0 {
+			barricadePositions = g.makeBarricades(l.barricades)
+		}
+		g.makeFormation(l.formation)
+		alienBullets = make([]*Bullet, int(aliensHorizontal*numRows/10))
+	}
+	tally.startWave()
+}
 
-	x, y, offset := alienStartx, alienStarty, 0
-	y, offset = makeAliens(x, y, rowsLg, aliensHorizontal,
-		alienSpriteWidth, alienSpriteHeight, rwdLg,
-		offset, fgAlien, bgAlien, lgAlienSprite)
-	y, offset = makeAliens(x, y, rowsMd, aliensHorizontal,
-		alienSpriteWidth, alienSpriteHeight, rwdMd,
-		offset, fgAlien, bgAlien, mdAlienSprite)
-	makeAliens(x, y, rowsSm, aliensHorizontal,
-		alienSpriteWidth, alienSpriteHeight, rwdSm,
-		offset, fgAlien, bgAlien, smAlienSprite)
+// startPlay starts a game, unless the level pack doesn't fit the terminal.
+// It was checked when the game started, but the terminal may have shrunk
+// since.
+func (g *Game) startPlay() bool {
+	if levels != nil {
+		if err := g.validateLevels(levelsFile, levels); err != nil {
+			log.Println(err)
+			first := err.(LevelErrors)[0]
+			g.startMsg = fmt.Sprintf("Level %d doesn't fit this terminal, see %s", first.level, logFilename)
+			return false
+		}
+	}
+	g.startMsg = ""
+	g.GoPlay()
+	return true
 }
 
 func (g *Game) GoPlay() {
@@ -684,16 +955,16 @@ func (g *Game) GoPlay() {
 	g.cfg = fgPlay
 	g.cbg = bgPlay
 
-	startx = g.w/2 - playerSpriteWidth/2
+	ship := ships[g.ship]
+	startx = g.w/2 - ship.width/2
 	starty = g.h - playerSpriteBottomOffset - playerSpriteHeight
 
 	if player == nil {
 		g.wipePlay()
-		player = &Player{
-			RegEntity{Entity{startx, starty, fgPlayer, bgPlayer}, playerSprite},
-			0,
-			initLives,
-			nil,
+		g.god, g.cheated = false, false
+		player = NewPlayer(startx, starty, ship, ship.lives(g.rules().lives))
+		if g.practice {
+			g.startPractice()
 		}
 
 		g.BeginNextLevel()
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+)
+
+// PracticeOption is used as an enum
+type PracticeOption uint8
+
+const (
+	PracticeMode PracticeOption = iota
+	PracticeDifficulty
+	PracticeShip
+	PracticeDives
+	PracticeLevel
+	PracticeInvulnerable
+	PracticeFire
+	PracticeSpeed
+	NumPracticeOptions
+)
+
+type PracticeSettings struct {
+	level        int
+	invulnerable bool
+	holdFire     bool
+
+	// index into practiceSpeeds
+	speed int
+}
+
+const (
+	practiceTitle    = "PRACTICE"
+	practiceWidth    = 44
+	practiceLabelPad = 16
+	maxPracticeLevel = 99
+	practiceHUD      = "PRACTICE  r restart wave  ESC stop"
+	practiceNote     = "Practice games don't count"
+)
+
+var (
+	practice = PracticeSettings{level: 1}
+
+	// highlighted option
+	practiceOpt PracticeOption
+
+	// how fast the aliens and bullets move, as a fraction of normal
+	practiceSpeeds = []float64{1, 0.75, 0.5, 0.25}
+)
+
+// onRecord reports whether the game being played counts towards the
+// highscores, stats and achievements
+func (g *Game) onRecord() bool {
+	return !g.preview && !g.practice && !g.cheated && !g.headless
+}
+
+// startPractice skips ahead to the chosen level, making the aliens as
+// tough as they would be by then
+func (g *Game) startPractice() {
+	simSpeed = practiceSpeeds[practice.speed]
+	for lvl < practice.level {
+		lvl++
+		g.escalate()
+	}
+}
+
+// restartWave puts the wave back the way it started, straight away
+func (g *Game) restartWave() {
+	g.WipeBullets()
+	fragments = make([]*FragmentGroup, 0)
+	ufo = nil
+	alienv = rightMove
+	alienSpriteIndex = 0
+	player.x = startx
+	barricadePositions = g.genBarricades()
+	g.setupWave()
+}
+
+func (g *Game) endPractice() {
+	g.practice = false
+	g.wipePlay()
+	g.GoPractice()
+}
+
+func onOff(b bool) string {
+	if b {
+		return "ON"
+	}
+	return "OFF"
+}
+
+func (g *Game) practiceValue(o PracticeOption) string {
+	switch o {
+	case PracticeMode:
+		return g.rules().name
+	case PracticeDifficulty:
+		return g.diff().name
+	case PracticeShip:
+		return ships[g.ship].name
+	case PracticeDives:
+		return onOff(g.dives)
+	case PracticeLevel:
+		return fmt.Sprintf("%d", practice.level)
+	case PracticeInvulnerable:
+		return onOff(practice.invulnerable)
+	case PracticeFire:
+		return onOff(!practice.holdFire)
+	case PracticeSpeed:
+		return fmt.Sprintf("%d%%", int(practiceSpeeds[practice.speed]*100))
+	}
+	return ""
+}
+
+var practiceLabels = map[PracticeOption]string{
+	PracticeMode:         "Mode",
+	PracticeDifficulty:   "Difficulty",
+	PracticeShip:         "Ship",
+	PracticeDives:        "Dive attacks",
+	PracticeLevel:        "Start level",
+	PracticeInvulnerable: "Invulnerable",
+	PracticeFire:         "Alien fire",
+	PracticeSpeed:        "Speed",
+}
+
+func (g *Game) DrawPractice() {
+	g.DrawMenu()
+
+	w, h := practiceWidth, int(NumPracticeOptions)+8
+	x, y := g.w/2-w/2, logoY
+	tbrect(x, y, w, h, fgModes, bgModes, true)
+
+	y++
+	tbprint(g.w/2-len(practiceTitle)/2, y, fgModes, bgModes, practiceTitle)
+	y += 2
+
+	x += 6
+	for o := PracticeMode; o < NumPracticeOptions; o++ {
+		tbprint(x, y, fgModes, bgModes, practiceLabels[o])
+		v := "< " + g.practiceValue(o) + " >"
+		if o == practiceOpt {
+			tbprint(x+practiceLabelPad, y, fgModeHighlight, bgModeHighlight, v)
+		} else {
+			tbprint(x+practiceLabelPad, y, fgModes, bgModes, v)
+		}
+		y++
+	}
+	y++
+
+	tbprint(g.w/2-len(practiceNote)/2, y, fgModes, bgModes, practiceNote)
+	y += 2
+
+	tbprintPrompt(y, "Press ", "Enter ", "to start, ", "ESC ", "to go back")
+
+	tbprint(g.w/2-len(g.startMsg)/2, logoY+h+1, fgHighscoresErr, bgMenu, g.startMsg)
+}
+
+func (g *Game) UpdatePractice() {
+	g.UpdateMenu()
+}
+
+// changePractice moves the highlighted option's value by d
+func (g *Game) changePractice(d int) {
+	switch practiceOpt {
+	case PracticeMode:
+		g.mode = GameMode((int(g.mode) + d + int(NumModes)) % int(NumModes))
+	case PracticeDifficulty:
+		g.difficulty = Difficulty((int(g.difficulty) + d + int(NumDifficulties)) % int(NumDifficulties))
+	case PracticeShip:
+		g.ship = (g.ship + d + len(ships)) % len(ships)
+	case PracticeDives:
+		g.dives = !g.dives
+	case PracticeLevel:
+		practice.level = clamp(practice.level+d, 1, maxPracticeLevel)
+	case PracticeInvulnerable:
+		practice.invulnerable = !practice.invulnerable
+	case PracticeFire:
+		practice.holdFire = !practice.holdFire
+	case PracticeSpeed:
+		practice.speed = clamp(practice.speed+d, 0, len(practiceSpeeds)-1)
+	}
+}
+
+func (g *Game) HandleKeyPractice(k termbox.Key) {
+	switch k {
+	case termbox.KeyArrowUp:
+		practiceOpt = (practiceOpt - 1 + NumPracticeOptions) % NumPracticeOptions
+	case termbox.KeyArrowDown:
+		practiceOpt = (practiceOpt + 1) % NumPracticeOptions
+	case termbox.KeyArrowLeft:
+		g.changePractice(-1)
+	case termbox.KeyArrowRight:
+		g.changePractice(1)
+	case termbox.KeyEsc:
+		g.GoMenu()
+		g.hmi = Practice
+	case termbox.KeyEnter:
+		fallthrough
+	case termbox.KeySpace:
+		g.practice = true
+		player = nil
+		if !g.startPlay() {
+			g.practice = false
+		}
+	}
+}
+
+func (g *Game) GoPractice() {
+	g.startMsg = ""
+	g.state = PracticeState
+	g.cfg = fgMenu
+	g.cbg = bgMenu
+}
@@ -0,0 +1,166 @@
+package main
+
+// With --record, every frame flushed to the screen is also written to an
+// asciicast v2 file (https://docs.asciinema.org/manual/asciicast/v2/),
+// which asciinema can play back. Each frame is a full repaint, with the
+// colours written the same way termbox does in Output256 mode, so the
+// recording looks like the game did.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+)
+
+// recorder is the session being recorded, if there is one
+var recorder *Recorder
+
+// Recorder writes frames to an asciicast file
+type Recorder struct {
+	f     *os.File
+	w     *bufio.Writer
+	start time.Time
+
+	// the last frame and its size, so unchanged frames can be skipped and
+	// resizes noted
+	last          string
+	width, height int
+}
+
+// AsciicastHeader is the first line of an asciicast v2 file
+type AsciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env"`
+}
+
+// newRecorder starts recording to f a screen of w by h
+func newRecorder(f *os.File, w, h int) (*Recorder, error) {
+	r := &Recorder{f: f, w: bufio.NewWriter(f), start: time.Now(), width: w, height: h}
+	err := json.NewEncoder(r.w).Encode(&AsciicastHeader{
+		Version:   2,
+		Width:     w,
+		Height:    h,
+		Timestamp: r.start.Unix(),
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// hide the cursor, as termbox does
+	return r, r.event("o", "\033[?25l")
+}
+
+// event writes an event of kind, such as "o" for output, that happened now
+func (r *Recorder) event(kind, data string) error {
+	t := math.Round(time.Since(r.start).Seconds()*1e6) / 1e6
+	line, err := json.Marshal([]interface{}{t, kind, data})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(r.w, "%s\n", line)
+	return err
+}
+
+// frame records what's in termbox's buffer, which is what was just flushed
+func (r *Recorder) frame() error {
+	w, h := termbox.Size()
+	if w != r.width || h != r.height {
+		r.width, r.height, r.last = w, h, ""
+		if err := r.event("r", fmt.Sprintf("%dx%d", w, h)); err != nil {
+			return err
+		}
+	}
+
+	frame := renderFrame(termbox.CellBuffer(), w, h)
+	if frame == r.last {
+		return nil
+	}
+	r.last = frame
+	return r.event("o", frame)
+}
+
+func (r *Recorder) Close() error {
+	err := r.event("o", "\033[0m\033[?25h")
+	if ferr := r.w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// renderFrame turns a cell buffer into the escape sequences that draw it
+// from the top left
+func renderFrame(cells []termbox.Cell, w, h int) string {
+	var b strings.Builder
+	b.WriteString("\033[H")
+	for y := 0; y < h; y++ {
+		if y > 0 {
+			b.WriteString("\r\n")
+		}
+		for x := 0; x < w && y*w+x < len(cells); {
+			c := cells[y*w+x]
+			if x == 0 || c.Fg != cells[y*w+x-1].Fg || c.Bg != cells[y*w+x-1].Bg {
+				writeSGR(&b, c.Fg, c.Bg)
+			}
+			ch, cw := c.Ch, runewidth.RuneWidth(c.Ch)
+			if ch == 0 || cw == 0 {
+				ch, cw = ' ', 1
+			}
+			b.WriteRune(ch)
+			x += cw
+		}
+	}
+	b.WriteString("\033[0m")
+	return b.String()
+}
+
+// writeSGR sets the colours and attributes of a cell, the way termbox does
+// in Output256 mode: colour n is written as n-1, and 0 is the default
+func writeSGR(w io.StringWriter, fg, bg termbox.Attribute) {
+	params := []string{"0"}
+	if c := fg & 0x1ff; c != termbox.ColorDefault {
+		params = append(params, "38;5;"+strconv.Itoa(int(c-1)))
+	}
+	if c := bg & 0x1ff; c != termbox.ColorDefault {
+		params = append(params, "48;5;"+strconv.Itoa(int(c-1)))
+	}
+	if fg&termbox.AttrBold != 0 {
+		params = append(params, "1")
+	}
+	if fg&termbox.AttrUnderline != 0 {
+		params = append(params, "4")
+	}
+	if fg&termbox.AttrReverse != 0 || bg&termbox.AttrReverse != 0 {
+		params = append(params, "7")
+	}
+	w.WriteString("\033[" + strings.Join(params, ";") + "m")
+}
+
+// flush draws the frame on the screen, and records it if the session is
+// being recorded. A recording that can't be written is given up on.
+func flush() {
+	termbox.Flush()
+	if recorder == nil {
+		return
+	}
+	if err := recorder.frame(); err != nil {
+		log.Printf("stopped recording: %v", err)
+		recorder.Close()
+		recorder = nil
+	}
+}
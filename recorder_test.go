@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestRenderFrame(t *testing.T) {
+	black := termbox.ColorBlack
+	cells := []termbox.Cell{
+		{Ch: 'A', Fg: neonGreen, Bg: black},
+		{Ch: 'B', Fg: neonGreen, Bg: black},
+		{Ch: '漢', Fg: red | termbox.AttrBold, Bg: black},
+		{Ch: ' ', Fg: red, Bg: black},
+		{Ch: 'x', Fg: termbox.ColorDefault, Bg: termbox.ColorDefault},
+		{Ch: 0, Fg: white, Bg: yellow},
+		{},
+		{},
+	}
+	want := "\033[H" +
+		"\033[0;38;5;82;48;5;0mAB\033[0;38;5;196;48;5;0;1m漢\r\n" +
+		"\033[0mx\033[0;38;5;15;48;5;226m \033[0m  " +
+		"\033[0m"
+	if got := renderFrame(cells, 4, 2); got != want {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Replay is everything needed to play a game again that isn't already on
+// its Highscore: the screen size, the settings, the level pack and every
+// key pressed. Given the same seed the game plays out exactly the same
+// way, so replaying the keys has to end with the same score.
+//
+// Levels is the file the pack was loaded from and Pack is what was in it,
+// in the level pack format, so the replay doesn't depend on the file.
+// Older replays only have Levels.
+type Replay struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Dives  bool   `json:"dives"`
+	Levels string `json:"levels,omitempty"`
+	Pack   string `json:"pack,omitempty"`
+	Ticks  int    `json:"ticks"`
+	Inputs Inputs `json:"inputs"`
+}
+
+// Input is a key pressed before the update numbered tick
+type Input struct {
+	tick int
+	key  termbox.Key
+}
+
+// Inputs are saved as the number of ticks since the previous input
+// followed by a letter for the key, such as "12L0F30R", to keep the
+// highscores file small.
+type Inputs []Input
+
+const (
+	// largest screen and longest game a replay can ask for. Each update
+	// costs more the bigger the screen, so it's kept to what a terminal
+	// can really be.
+	maxReplayWidth  = 400
+	maxReplayHeight = 150
+	maxReplayTicks  = 60 * 60 * fps
+
+	// how often replayGame checks whether it's out of time
+	deadlineEvery = 1000
+
+	verifiedOK     = "ok"
+	verifiedFailed = "failed"
+)
+
+var inputKeys = map[termbox.Key]byte{
+	termbox.KeyArrowLeft:  'L',
+	termbox.KeyArrowRight: 'R',
+	termbox.KeySpace:      'F',
+}
+
+// recording is what's been pressed so far this game
+var recording Inputs
+
+func recordInput(k termbox.Key) {
+	if _, ok := inputKeys[k]; ok {
+		recording = append(recording, Input{ticks, k})
+	}
+}
+
+func (in Inputs) MarshalText() ([]byte, error) {
+	text := make([]byte, 0, len(in)*3)
+	last := 0
+	for _, i := range in {
+		text = strconv.AppendInt(text, int64(i.tick-last), 10)
+		text = append(text, inputKeys[i.key])
+		last = i.tick
+	}
+	return text, nil
+}
+
+func (in *Inputs) UnmarshalText(text []byte) error {
+	*in = make(Inputs, 0)
+	tick, start := 0, 0
+	for i, c := range text {
+		if c >= '0' && c <= '9' {
+			continue
+		}
+		d, err := strconv.Atoi(string(text[start:i]))
+		if err != nil || d < 0 {
+			return fmt.Errorf("bad input %q", text[start:i+1])
+		}
+		k, ok := inputKey(c)
+		if !ok {
+			return fmt.Errorf("unknown key %q", c)
+		}
+		tick += d
+		*in = append(*in, Input{tick, k})
+		start = i + 1
+	}
+	if start != len(text) {
+		return fmt.Errorf("input %q has no key", text[start:])
+	}
+	return nil
+}
+
+func inputKey(c byte) (termbox.Key, bool) {
+	for k, l := range inputKeys {
+		if l == c {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// newReplay makes the replay for the game that's just finished
+func (g *Game) newReplay() *Replay {
+	r := &Replay{Width: g.w, Height: g.h, Dives: g.dives, Ticks: ticks, Inputs: recording}
+	if levelsFile != "" {
+		r.Levels, _ = filepath.Abs(levelsFile)
+		r.Pack = string(formatLevels(levels))
+	}
+	return r
+}
+
+// verifyHighscore plays h again without a screen and checks that the game
+// ends when it did with the same score. It gives up at deadline, unless
+// that's zero.
+func verifyHighscore(h *Highscore, deadline time.Time) error {
+	score, end, err := replayGame(h, deadline)
+	switch {
+	case err != nil:
+		return err
+	case end < 0:
+		return fmt.Errorf("game was still going after %d ticks", h.Replay.Ticks)
+	case end != h.Replay.Ticks:
+		return fmt.Errorf("game ended after %d ticks, not %d", end, h.Replay.Ticks)
+	case score != h.Score:
+		return fmt.Errorf("replay scored %d", score)
+	case h.Dives != h.Replay.Dives:
+		return fmt.Errorf("highscore and replay disagree about dive attacks")
+	}
+	if c := (&Game{w: h.Replay.Width, h: h.Replay.Height}).sizeClass(); sizeClasses[c].key != h.Size {
+		return fmt.Errorf("replay was on a %s screen", sizeClasses[c].key)
+	}
+	return nil
+}
+
+// replayGame plays h's replay without a screen, for at most its number of
+// ticks or until deadline if that isn't zero, and returns the score and the
+// tick the game ended on, or -1 if it didn't end.
+func replayGame(h *Highscore, deadline time.Time) (score, end int, err error) {
+	p, err := startReplay(h)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer p.stop()
+	for !p.over() {
+		if ticks%deadlineEvery == 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			return 0, 0, fmt.Errorf("replay took too long, gave up after %d ticks", ticks)
+		}
+		p.step()
+	}
+
+	if p.state == PlayState {
+		return player.score, -1, nil
+	}
+	return player.score, ticks, nil
+}
+
+// Playback is a replay being played, in a Game of its own that has the
+// replay's screen size and settings. As games share the play globals only
+// one can be played at a time.
+type Playback struct {
+	*Game
+	replay *Replay
+
+	// index into replay.Inputs of the next key to press
+	next int
+
+	// levels being played before the replay, put back by stop
+	levels     []*Level
+	levelsFile string
+}
+
+// startReplay starts playing h's replay, which has to be stopped once it's
+// finished with
+func startReplay(h *Highscore) (*Playback, error) {
+	r := h.Replay
+	switch {
+	case r.Width < logoLineLength+8 || r.Width > maxReplayWidth ||
+		r.Height < logoY+logoHeight+7 || r.Height > maxReplayHeight:
+		return nil, fmt.Errorf("can't play on a %dx%d screen", r.Width, r.Height)
+	case r.Ticks < 0 || r.Ticks > maxReplayTicks:
+		return nil, fmt.Errorf("can't play for %d ticks", r.Ticks)
+	}
+
+	g := NewGame()
+	g.headless = true
+	g.w, g.h = r.Width, r.Height
+	g.mode, g.difficulty = modeByKey(h.Mode), difficultyByKey(h.Difficulty)
+	g.dives, g.seed = r.Dives, h.Seed
+	g.ship = -1
+	for i, s := range ships {
+		if s.name == h.Ship {
+			g.ship = i
+		}
+	}
+	if g.ship < 0 {
+		return nil, fmt.Errorf("unknown ship %q", h.Ship)
+	}
+
+	p := &Playback{Game: g, replay: r, levels: levels, levelsFile: levelsFile}
+	levels, levelsFile = nil, ""
+	if r.Levels != "" || r.Pack != "" {
+		pack, err := replayLevels(g, r)
+		if err != nil {
+			p.stop()
+			return nil, err
+		}
+		levels, levelsFile = pack, r.Levels
+	}
+
+	player = nil
+	g.GoPlay()
+	return p, nil
+}
+
+// replayLevels reads r's level pack and checks it fits the screen it was
+// played on
+func replayLevels(g *Game, r *Replay) ([]*Level, error) {
+	var (
+		pack []*Level
+		err  error
+	)
+	if r.Pack != "" {
+		pack, err = parseLevels(r.Levels, []byte(r.Pack))
+	} else {
+		pack, err = loadLevels(r.Levels)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(pack) == 0 {
+		return nil, fmt.Errorf("%s has no levels", r.Levels)
+	}
+	if err := g.validateLevels(r.Levels, pack); err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// step presses the keys pressed before this tick and plays it
+func (p *Playback) step() {
+	for ; p.next < len(p.replay.Inputs) && p.replay.Inputs[p.next].tick <= ticks; p.next++ {
+		p.HandleKeyPlay(p.replay.Inputs[p.next].key)
+	}
+	p.UpdatePlay()
+}
+
+// over reports whether the game has ended or the replay has run out
+func (p *Playback) over() bool {
+	return p.state != PlayState || ticks >= p.replay.Ticks
+}
+
+// stop puts back the levels that were being played, and clears the player
+// so the next game starts afresh
+func (p *Playback) stop() {
+	levels, levelsFile = p.levels, p.levelsFile
+	player = nil
+}
+
+// verifyCommand replays every highscore, saving which ones were verified
+func verifyCommand(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Parse(args)
+
+	g := NewGame()
+	g.loadHighscores()
+	if g.highscoresErr != nil {
+		return g.highscoresErr
+	}
+
+	scores := g.sortedHighscores()
+	failed := 0
+	for _, h := range scores {
+		result := "no replay"
+		if h.Replay != nil {
+			if err := verifyHighscore(h, time.Time{}); err != nil {
+				h.Verified, result = verifiedFailed, "FAILED: "+err.Error()
+				failed++
+			} else {
+				h.Verified, result = verifiedOK, verifiedOK
+			}
+		}
+		fmt.Printf("%-32s %-10s %10d  %s\n", boardOf(h), h.Name, h.Score, result)
+	}
+
+	if err := g.saveHighscores(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d highscores failed verification", failed, len(scores))
+	}
+	return nil
+}
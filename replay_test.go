@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+// TestReplayLevels checks that a replay brings its level pack along and
+// won't play one that doesn't fit its screen
+func TestReplayLevels(t *testing.T) {
+	data, err := ioutil.ReadFile("example.levels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Highscore{Name: "ace", Mode: "classic", Difficulty: "normal", Ship: ships[0].name, Seed: 1,
+		Replay: &Replay{Width: 120, Height: 45, Levels: "/nowhere/example.levels", Pack: string(data), Ticks: 100}}
+	p, err := startReplay(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for !p.over() {
+		p.step()
+	}
+	p.stop()
+
+	h.Replay.Width = 80
+	if _, err := startReplay(h); err == nil {
+		t.Error("played a pack that doesn't fit the screen")
+	}
+	if levels != nil {
+		t.Error("levels weren't put back")
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+// Every game that counts is saved as a replay in the replays directory
+// next to the highscores, one file per game, which REPLAYS on the main menu
+// lists and plays back. A replay file holds the game as a one line
+// Highscore, whether or not it was one, so it plays the same way verify
+// does. Only the newest maxReplays are kept.
+//
+// Practice games, previews from the editor and games where the console was
+// used don't count, see onRecord, so they aren't saved.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	replaysDirname = "replays"
+	replaySuffix   = ".replay"
+	replayNameFmt  = "20060102-150405.000"
+	maxReplays     = 50
+
+	replaysTitle   = "REPLAYS"
+	replayRow      = "%-16s %-10s %-8s %10s %5s %-8s"
+	replayDateFmt  = "2006-01-02 15:04"
+	replaysPrompt  = "ENTER to watch, ESC to exit"
+	noReplays      = "No replays yet, they're saved as you play"
+	replaysNote    = "Practice games and console use aren't saved"
+	replayControls = "SPACE pause  1 2 4 speed  > step  ESC exit"
+	replayEnded    = "END"
+	replayPausedAt = "PAUSED"
+
+	// rows of the list shown at once, fewer if the terminal is short
+	maxReplayRows = 10
+	minReplayRows = 3
+)
+
+// replaySpeeds are the updates played each frame
+var replaySpeeds = []int{1, 2, 4}
+
+// SavedReplay is a replay file and the game in it
+type SavedReplay struct {
+	filename string
+	game     *Highscore
+}
+
+// validReplay reports whether h can be played. Unlike a highscore it
+// doesn't need a name.
+func validReplay(h *Highscore) bool {
+	return h != nil && h.Replay != nil && h.Score >= 0 && modeByKey(h.Mode) != NumModes &&
+		difficultyByKey(h.Difficulty) != NumDifficulties
+}
+
+// saveReplay saves the game h, dropping the oldest replays once there are
+// more than maxReplays
+func saveReplay(h *Highscore) error {
+	dir := dataPath(replaysDirname)
+	if err := os.MkdirAll(dir, dataDirPerm); err != nil {
+		return err
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, h.Date.Format(replayNameFmt)+replaySuffix), data, 0666); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+replaySuffix))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for ; len(files) > maxReplays; files = files[1:] {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadReplays reads the saved replays, newest first, skipping any that
+// can't be played
+func loadReplays() []*SavedReplay {
+	files, _ := filepath.Glob(filepath.Join(dataPath(replaysDirname), "*"+replaySuffix))
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+
+	replays := make([]*SavedReplay, 0, len(files))
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		var h *Highscore
+		if err := json.Unmarshal(data, &h); err != nil || !validReplay(h) {
+			log.Printf("%s: skipping replay that can't be played", f)
+			continue
+		}
+		replays = append(replays, &SavedReplay{f, h})
+	}
+	return replays
+}
+
+func replayColumns(h *Highscore) string {
+	return fmt.Sprintf(replayRow, h.Date.Format(replayDateFmt), modes[modeByKey(h.Mode)].name,
+		difficulties[difficultyByKey(h.Difficulty)].name, fmt.Sprintf("%d", h.Score), fmt.Sprintf("%d", h.Level), h.Ship)
+}
+
+func (g *Game) replayRows() int {
+	spare := g.h - logoY - 10
+	switch {
+	case spare > maxReplayRows:
+		return maxReplayRows
+	case spare < minReplayRows:
+		return minReplayRows
+	}
+	return spare
+}
+
+// selectReplay moves the selection by d, scrolling to keep it in view
+func (g *Game) selectReplay(d int) {
+	g.replaySel += d
+	if g.replaySel >= len(g.replays) {
+		g.replaySel = len(g.replays) - 1
+	}
+	if g.replaySel < 0 {
+		g.replaySel = 0
+	}
+
+	rows := g.replayRows()
+	switch {
+	case g.replaySel < g.replayScroll:
+		g.replayScroll = g.replaySel
+	case g.replaySel >= g.replayScroll+rows:
+		g.replayScroll = g.replaySel - rows + 1
+	}
+}
+
+func (g *Game) DrawReplays() {
+	g.DrawMenu()
+
+	header := fmt.Sprintf(replayRow, "DATE", "MODE", "DIFF", "SCORE", "LEVEL", "SHIP")
+	rows := g.replayRows()
+	w, h := len(header)+2*highscoresWidthPad, rows+9
+	x, y := g.w/2-w/2, logoY
+	tbrect(x, y, w, h, fgHighscores, bgHighscores, true)
+	markx := x + w - highscoresWidthPad + 1
+
+	y += 2
+	tbprint(g.w/2-len(replaysTitle)/2, y, fgHighscores, bgHighscores, replaysTitle)
+
+	y += 2
+	x += highscoresWidthPad
+	tbprint(x, y, fgHighscoresHeader, bgHighscores, header)
+	y++
+	if g.replayScroll > 0 {
+		tbprint(markx, y, magenta, bgHighscores, scrollUpMark)
+	}
+	for i := g.replayScroll; i < g.replayScroll+rows && i < len(g.replays); i++ {
+		if i == g.replaySel {
+			tbprint(x, y+i-g.replayScroll, fgHighscoreRecent, bgHighscoreRecent, replayColumns(g.replays[i].game))
+		} else {
+			tbprint(x, y+i-g.replayScroll, fgHighscores, bgHighscores, replayColumns(g.replays[i].game))
+		}
+	}
+	if g.replayScroll+rows < len(g.replays) {
+		tbprint(markx, y+rows-1, magenta, bgHighscores, scrollDownMark)
+	}
+	y += rows
+
+	msg, fg := g.replaysMsg, termbox.Attribute(fgHighscoresErr)
+	switch {
+	case msg != "":
+	case len(g.replays) == 0:
+		msg = noReplays
+	default:
+		msg, fg = replaysNote, fgHighscores
+	}
+	y++
+	tbprint(g.w/2-len(msg)/2, y, fg, bgHighscores, msg)
+
+	y += 2
+	tbprint(g.w/2-len(replaysPrompt)/2, y, magenta, bgHighscores, replaysPrompt)
+}
+
+func (g *Game) UpdateReplays() {
+	g.UpdateMenu()
+}
+
+func (g *Game) HandleKeyReplays(k termbox.Key) {
+	switch k {
+	case termbox.KeyArrowUp:
+		g.selectReplay(-1)
+	case termbox.KeyArrowDown:
+		g.selectReplay(1)
+	case termbox.KeyPgup:
+		g.selectReplay(-g.replayRows())
+	case termbox.KeyPgdn:
+		g.selectReplay(g.replayRows())
+	case termbox.KeyEnter:
+		if len(g.replays) > 0 {
+			g.watchReplay(g.replays[g.replaySel])
+		}
+	case termbox.KeyEsc:
+		g.GoMenu()
+		g.hmi = Replays
+	}
+}
+
+func (g *Game) GoReplays() {
+	g.replays, g.replaysMsg = loadReplays(), ""
+	g.replaySel, g.replayScroll = 0, 0
+	g.state = ReplaysState
+	g.cfg = fgMenu
+	g.cbg = bgMenu
+}
+
+// watchReplay starts playing back s, if it fits on the screen
+func (g *Game) watchReplay(s *SavedReplay) {
+	r := s.game.Replay
+	if r.Width > g.w || r.Height > g.h {
+		g.replaysMsg = fmt.Sprintf("This replay needs a %dx%d terminal", r.Width, r.Height)
+		return
+	}
+	p, err := startReplay(s.game)
+	if err != nil {
+		log.Printf("%s: %v", s.filename, err)
+		g.replaysMsg = "Couldn't play this replay: " + err.Error()
+		return
+	}
+
+	g.playback, g.replayPaused, g.replaySpeed = p, false, 0
+	g.state = ReplayState
+	g.cfg = fgPlay
+	g.cbg = bgPlay
+}
+
+// DrawReplay draws the game being played back with the real renderer, and
+// the playback controls along the bottom
+func (g *Game) DrawReplay() {
+	g.playback.DrawPlay()
+
+	status := fmt.Sprintf("%dx", replaySpeeds[g.replaySpeed])
+	switch {
+	case g.playback.over():
+		status = replayEnded
+	case g.replayPaused:
+		status = replayPausedAt
+	}
+	bar := fmt.Sprintf("%s %-6s %s / %s   %s", replaysTitle, status, replayClock(ticks),
+		replayClock(g.playback.replay.Ticks), replayControls)
+	tbprint(g.w/2-len(bar)/2, g.h-1, magenta, bgPlay, bar)
+}
+
+func replayClock(t int) string {
+	s := t / fps
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+func (g *Game) UpdateReplay() {
+	if g.replayPaused {
+		return
+	}
+	for i := 0; i < replaySpeeds[g.replaySpeed] && !g.playback.over(); i++ {
+		g.playback.step()
+	}
+}
+
+// stepReplay pauses the replay and plays a single update
+func (g *Game) stepReplay() {
+	g.replayPaused = true
+	if !g.playback.over() {
+		g.playback.step()
+	}
+}
+
+func (g *Game) HandleKeyReplay(k termbox.Key) {
+	switch k {
+	case termbox.KeySpace:
+		g.replayPaused = !g.replayPaused
+	case termbox.KeyArrowRight:
+		g.stepReplay()
+	case termbox.KeyEsc:
+		g.playback.stop()
+		g.playback = nil
+		g.state = ReplaysState
+		g.cfg = fgMenu
+		g.cbg = bgMenu
+	}
+}
+
+func (g *Game) HandleCharReplay(ch rune) {
+	switch ch {
+	case '.', '>':
+		g.stepReplay()
+	case '1':
+		g.replaySpeed = 0
+	case '2':
+		g.replaySpeed = 1
+	case '4':
+		g.replaySpeed = 2
+	}
+}
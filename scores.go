@@ -0,0 +1,382 @@
+package main
+
+// Highscores are kept in a versioned JSON file, for example:
+//
+//	{
+//	  "version": 1,
+//	  "scores": [
+//	    {"name": "ace", "score": 1200, "date": "2026-10-19T18:04:05Z",
+//	     "level": 4, "duration": 312, "difficulty": "normal", "seed": 1234,
+//	     "mode": "classic", "size": "medium", "ship": "CLASSIC"}
+//	  ]
+//	}
+//
+// duration is in seconds, and size is the terminal's SizeClass. Scores
+// saved before there were size classes count as medium. Games with dive
+// attacks or a level pack go on boards of their own, named after the pack.
+//
+// Newer scores also have a replay, see Replay, and whether the verify
+// command could replay them.
+//
+// Older versions of the game kept name:score:ship lines in a file per mode
+// (see ModeRules.legacyFile) in the working directory, which are read in
+// the first time the game runs without a highscores file.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type Highscore struct {
+	Name       string    `json:"name"`
+	Score      int       `json:"score"`
+	Date       time.Time `json:"date"`
+	Level      int       `json:"level"`
+	Duration   int       `json:"duration"`
+	Difficulty string    `json:"difficulty"`
+	Seed       int64     `json:"seed"`
+	Mode       string    `json:"mode"`
+	Size       string    `json:"size"`
+	Ship       string    `json:"ship"`
+
+	// Dives and Levels, the name of the level pack if there was one, go
+	// on boards of their own
+	Dives  bool   `json:"dives,omitempty"`
+	Levels string `json:"levels,omitempty"`
+
+	// User is who set the highscore, on a shared file
+	User string `json:"user,omitempty"`
+
+	// Verified is set by the verify command, to verifiedOK or
+	// verifiedFailed
+	Replay   *Replay `json:"replay,omitempty"`
+	Verified string  `json:"verified,omitempty"`
+}
+
+type ByScore []*Highscore
+
+func (a ByScore) Len() int           { return len(a) }
+func (a ByScore) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByScore) Less(i, j int) bool { return a[i].Score < a[j].Score }
+
+type HighscoreFile struct {
+	Version int          `json:"version"`
+	Scores  []*Highscore `json:"scores"`
+
+	// Signature is left out of exports and the server's file, see
+	// signHighscores
+	Signature string `json:"signature,omitempty"`
+}
+
+const (
+	highscoresFilename = "highscores.json"
+	highscoresVersion  = 1
+)
+
+// HighscoreProblem is used as an enum
+type HighscoreProblem uint8
+
+const (
+	MalformedEntry HighscoreProblem = iota
+	BadName
+	BadScore
+	UnknownMode
+	UnknownDifficulty
+	UnknownSize
+)
+
+var highscoreProblems = map[HighscoreProblem]string{
+	MalformedEntry:    "malformed entry",
+	BadName:           "name must be 3-10 characters wide, without " + highscoreSeparator,
+	BadScore:          "bad score",
+	UnknownMode:       "unknown mode",
+	UnknownDifficulty: "unknown difficulty",
+	UnknownSize:       "unknown size",
+}
+
+// HighscoreError is an entry that had to be skipped. line is set for the
+// older name:score:ship files and entry, counting from 1, for JSON ones.
+type HighscoreError struct {
+	line    int
+	entry   int
+	problem HighscoreProblem
+	text    string
+}
+
+func (e *HighscoreError) Error() string {
+	where := fmt.Sprintf("entry %d", e.entry)
+	if e.line > 0 {
+		where = fmt.Sprintf("line %d", e.line)
+	}
+	return fmt.Sprintf("%s: %s: %q", where, highscoreProblems[e.problem], e.text)
+}
+
+// rawHighscoreFile is read first, so one bad entry doesn't spoil the rest
+type rawHighscoreFile struct {
+	Version int               `json:"version"`
+	Scores  []json.RawMessage `json:"scores"`
+}
+
+// parseHighscores reads a highscores file in either format, skipping any
+// entries that don't make sense. Legacy lines are filed under mode. err is
+// only set if the whole file is unusable.
+func parseHighscores(data []byte, mode GameMode) (scores []*Highscore, skipped []*HighscoreError, err error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		scores, skipped = parseLegacyHighscores(data, mode)
+		return scores, skipped, nil
+	}
+
+	var f rawHighscoreFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case f.Version == 0:
+		return nil, nil, fmt.Errorf("highscores file has no version")
+	case f.Version > highscoresVersion:
+		return nil, nil, fmt.Errorf("highscores file is version %d, this game only understands up to %d",
+			f.Version, highscoresVersion)
+	}
+
+	scores = make([]*Highscore, 0, len(f.Scores))
+	for i, raw := range f.Scores {
+		var h *Highscore
+		if err := json.Unmarshal(raw, &h); err != nil || h == nil {
+			skipped = append(skipped, &HighscoreError{0, i + 1, MalformedEntry, string(raw)})
+			continue
+		}
+		if h.Size == "" {
+			h.Size = sizeClasses[MediumSize].key
+		}
+		if p, ok := h.check(); !ok {
+			skipped = append(skipped, &HighscoreError{0, i + 1, p, string(raw)})
+			continue
+		}
+		scores = append(scores, h)
+	}
+	return scores, skipped, nil
+}
+
+// parseLegacyHighscores reads the name:score:ship lines older versions
+// wrote. The ship was added later, so it can be missing.
+func parseLegacyHighscores(data []byte, mode GameMode) ([]*Highscore, []*HighscoreError) {
+	scores := make([]*Highscore, 0)
+	skipped := make([]*HighscoreError, 0)
+	for n, l := range strings.Split(string(data), "\n") {
+		l = strings.TrimRight(l, "\r")
+		if l == "" {
+			continue
+		}
+		parts := strings.Split(l, highscoreSeparator)
+		if len(parts) == 2 {
+			parts = append(parts, "")
+		}
+		if len(parts) != 3 {
+			skipped = append(skipped, &HighscoreError{n + 1, 0, MalformedEntry, l})
+			continue
+		}
+
+		h := &Highscore{Name: parts[0], Difficulty: difficulties[Normal].key, Mode: modes[mode].key,
+			Size: sizeClasses[MediumSize].key, Ship: parts[2]}
+		i, err := strconv.Atoi(parts[1])
+		if err != nil {
+			skipped = append(skipped, &HighscoreError{n + 1, 0, BadScore, l})
+			continue
+		}
+		h.Score = i
+		if p, ok := h.check(); !ok {
+			skipped = append(skipped, &HighscoreError{n + 1, 0, p, l})
+			continue
+		}
+		scores = append(scores, h)
+	}
+	return scores, skipped
+}
+
+// check finds the first problem with a highscore, if there is one
+func (h *Highscore) check() (HighscoreProblem, bool) {
+	switch {
+	case !validName(h.Name):
+		return BadName, false
+	case h.Score < 0:
+		return BadScore, false
+	case !utf8.ValidString(h.Ship), !utf8.ValidString(h.User), !utf8.ValidString(h.Levels):
+		return MalformedEntry, false
+	case modeByKey(h.Mode) == NumModes:
+		return UnknownMode, false
+	case difficultyByKey(h.Difficulty) == NumDifficulties:
+		return UnknownDifficulty, false
+	case sizeByKey(h.Size) == NumSizeClasses:
+		return UnknownSize, false
+	}
+	return 0, true
+}
+
+func formatHighscores(scores []*Highscore) ([]byte, error) {
+	if scores == nil {
+		scores = make([]*Highscore, 0)
+	}
+	return json.MarshalIndent(&HighscoreFile{Version: highscoresVersion, Scores: scores}, "", "  ")
+}
+
+// logSkipped notes the entries that were skipped and how many there were,
+// for the Highscores screen
+func (g *Game) logSkipped(filename string, skipped []*HighscoreError) {
+	for _, e := range skipped {
+		log.Printf("%s: skipping %v", filename, e)
+	}
+	g.highscoresSkipped += len(skipped)
+}
+
+func (g *Game) loadHighscores() {
+	key, created, err := loadKey()
+	if err != nil {
+		log.Printf("not signing highscores: %v", err)
+	}
+	g.highscoresKey, g.keyCreated = key, created
+
+	data, err := ioutil.ReadFile(highscoresPath())
+	if os.IsNotExist(err) {
+		g.migrateHighscores()
+		return
+	}
+	if err == nil {
+		var skipped []*HighscoreError
+		g.highscores, skipped, err = parseHighscores(data, ClassicMode)
+		g.logSkipped(highscoresFilename, skipped)
+	}
+	if err == nil {
+		g.checkSignature(highscoresFilename, data)
+	}
+	if err != nil {
+		// don't write over what might be someone's scores
+		log.Println(err)
+		g.highscoresErr = err
+	}
+}
+
+// migrateHighscores reads in the files older versions wrote and saves them
+// in the current format. The old files are left alone.
+func (g *Game) migrateHighscores() {
+	found := false
+	for m := ClassicMode; m < NumModes; m++ {
+		data, err := ioutil.ReadFile(modes[m].legacyFile)
+		if err != nil {
+			continue
+		}
+		found = true
+		scores, skipped, _ := parseHighscores(data, m)
+		g.logSkipped(modes[m].legacyFile, skipped)
+		g.highscores = append(g.highscores, scores...)
+	}
+
+	if found {
+		if err := g.saveHighscores(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// saveHighscores writes the highscores out, merged with any that another
+// game saved since they were loaded
+func (g *Game) saveHighscores() error {
+	if g.highscoresErr != nil {
+		return fmt.Errorf("not saving highscores: %v", g.highscoresErr)
+	}
+
+	filename := highscoresPath()
+	unlock, err := lockFile(filename + lockSuffix)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := ioutil.ReadFile(filename)
+	switch {
+	case err == nil:
+		saved, _, err := parseHighscores(data, ClassicMode)
+		if err != nil {
+			return err
+		}
+		g.checkSignature(highscoresFilename, data)
+		g.highscores = mergeHighscores(g.highscores, saved)
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	data, err = g.formatSignedHighscores(g.highscores)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data, highscoresPerm())
+}
+
+// resetHighscores deletes every highscore. Unlike saveHighscores it doesn't
+// mind if the file couldn't be read or was modified, so it can be used to
+// start again.
+func (g *Game) resetHighscores() error {
+	filename := highscoresPath()
+	unlock, err := lockFile(filename + lockSuffix)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	g.highscoresModified = false
+	data, err := g.formatSignedHighscores(nil)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data, highscoresPerm())
+}
+
+// key identifies a highscore, so the same one isn't counted twice when
+// merging
+func (h *Highscore) key() string {
+	return fmt.Sprintf("%s:%d:%d:%d:%s:%s:%s:%s:%s:%v:%s", h.Name, h.Score, h.Date.UnixNano(), h.Seed,
+		h.Mode, h.Difficulty, h.Size, h.Ship, h.User, h.Dives, h.Levels)
+}
+
+// mergeHighscores puts two lots of highscores together, keeping the best on
+// each board. Where both have the same highscore, the one from a is kept.
+func mergeHighscores(a, b []*Highscore) []*Highscore {
+	seen := make(map[string]bool)
+	scores := make([]*Highscore, 0, len(a)+len(b))
+	for _, h := range append(append([]*Highscore(nil), a...), b...) {
+		if !seen[h.key()] {
+			seen[h.key()] = true
+			scores = append(scores, h)
+		}
+	}
+	return trimBoards(scores)
+}
+
+// trimBoards drops the highscores that don't make their board
+func trimBoards(scores []*Highscore) []*Highscore {
+	sorted := append([]*Highscore(nil), scores...)
+	sort.Stable(sort.Reverse(ByScore(sorted)))
+
+	counts := make(map[Board]int)
+	kept := make([]*Highscore, 0, len(sorted))
+	for _, h := range sorted {
+		if b := boardOf(h); counts[b] < maxHighscores {
+			counts[b]++
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
+
+// addHighscore puts h on its board, dropping whatever falls off the end
+func (g *Game) addHighscore(h *Highscore) {
+	g.highscores = trimBoards(append(g.highscores, h))
+}
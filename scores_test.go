@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseHighscores(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		data    string
+		scores  int
+		skipped []HighscoreProblem
+		err     bool
+	}{
+		{"legacy", "ace:1200:DART\nbob:800\n", 2, nil, false},
+		{"legacy trailing newlines", "ace:1200\n\n\r\n", 1, nil, false},
+		{"legacy bad score", "ace:12x0\nbob:800\n", 1, []HighscoreProblem{BadScore}, false},
+		{"legacy bad name", "al:100\nthisnameistoolong:100\nb\xffd:100\n", 0,
+			[]HighscoreProblem{BadName, BadName, BadName}, false},
+		{"legacy malformed", "ace\nace:1:2:3\nace:1:\xfd\n", 0,
+			[]HighscoreProblem{MalformedEntry, MalformedEntry, MalformedEntry}, false},
+		{"json", `{"version":1,"scores":[{"name":"ace","score":10,"mode":"classic","difficulty":"easy"}]}`,
+			1, nil, false},
+		{"json bad entries", `{"version":1,"scores":[{"name":"ace","score":"x"},null,` +
+			`{"name":"ace","score":-1,"mode":"classic","difficulty":"easy"},` +
+			`{"name":"ace","score":1,"mode":"nope","difficulty":"easy"},` +
+			`{"name":"ace","score":1,"mode":"classic","difficulty":"nope"},` +
+			`{"name":"ace","score":1,"mode":"classic","difficulty":"easy","size":"nope"}]}`,
+			0, []HighscoreProblem{MalformedEntry, MalformedEntry, BadScore, UnknownMode, UnknownDifficulty, UnknownSize},
+			false},
+		{"json replay", `{"version":1,"scores":[{"name":"ace","score":10,"mode":"classic","difficulty":"easy",` +
+			`"replay":{"width":120,"height":45,"ticks":90,"inputs":"3L0F12R"}},` +
+			`{"name":"ace","score":10,"mode":"classic","difficulty":"easy","replay":{"inputs":"3L0Q"}},` +
+			`{"name":"ace","score":10,"mode":"classic","difficulty":"easy","replay":{"inputs":"3L4"}}]}`,
+			1, []HighscoreProblem{MalformedEntry, MalformedEntry}, false},
+		{"json names", `{"version":1,"scores":[{"name":"漢字","score":1,"mode":"classic","difficulty":"easy"},` +
+			`{"name":"漢字漢字漢字","score":1,"mode":"classic","difficulty":"easy"},` +
+			`{"name":"a:b","score":1,"mode":"classic","difficulty":"easy"}]}`,
+			1, []HighscoreProblem{BadName, BadName}, false},
+		{"json no version", `{"scores":[]}`, 0, nil, true},
+		{"json newer version", `{"version":99,"scores":[]}`, 0, nil, true},
+		{"json truncated", `{"version":1,"scores":[`, 0, nil, true},
+	} {
+		scores, skipped, err := parseHighscores([]byte(c.data), ClassicMode)
+		if (err != nil) != c.err {
+			t.Errorf("%s: got error %v", c.name, err)
+			continue
+		}
+		if len(scores) != c.scores {
+			t.Errorf("%s: got %d scores, want %d", c.name, len(scores), c.scores)
+		}
+		if len(skipped) != len(c.skipped) {
+			t.Errorf("%s: skipped %v, want %v", c.name, skipped, c.skipped)
+			continue
+		}
+		for i, e := range skipped {
+			if e.problem != c.skipped[i] {
+				t.Errorf("%s: skipped %v, want %s", c.name, e, highscoreProblems[c.skipped[i]])
+			}
+		}
+	}
+}
+
+func FuzzParseHighscores(f *testing.F) {
+	for _, s := range []string{
+		"ace:1200:DART\nbob:800\n",
+		"ace:12x0\n",
+		"0\xff0:0\n",
+		"ace::\n:1\n",
+		`{"version":1,"scores":[{"name":"ace","score":10,"date":"2026-10-19T18:04:05Z",` +
+			`"level":4,"duration":312,"difficulty":"normal","seed":1234,"mode":"classic","ship":"DART"}]}`,
+		`{"version":1,"scores":[null,1,{"name":"ace"}]}`,
+		`{"version":1,"scores":[{"name":"ace","score":1,"mode":"classic","difficulty":"hard",` +
+			`"replay":{"width":120,"height":45,"ticks":9,"inputs":"0L0R3F"},"verified":"ok"}]}`,
+	} {
+		f.Add([]byte(s))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		scores, skipped, err := parseHighscores(data, ClassicMode)
+		if err != nil {
+			if scores != nil || skipped != nil {
+				t.Fatalf("got entries along with error %v", err)
+			}
+			return
+		}
+		for _, h := range scores {
+			if p, ok := h.check(); !ok {
+				t.Fatalf("kept %+v: %s", h, highscoreProblems[p])
+			}
+		}
+
+		// whatever was kept should survive being saved and read back in
+		out, err := formatHighscores(scores)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, skipped, err := parseHighscores(out, ClassicMode)
+		if err != nil || len(skipped) > 0 || len(again) != len(scores) {
+			t.Fatalf("round trip: %d scores became %d, skipped %v, error %v", len(scores), len(again), skipped, err)
+		}
+		for i := range scores {
+			if scores[i].key() != again[i].key() {
+				t.Fatalf("round trip: %+v became %+v", scores[i], again[i])
+			}
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var scoresCommands = map[string]func(g *Game, args []string) error{
+	"list":   scoresList,
+	"export": scoresExport,
+	"import": scoresImport,
+	"reset":  scoresReset,
+}
+
+var csvHeader = []string{"name", "score", "date", "level", "duration", "difficulty", "seed", "mode", "size",
+	"ship", "dives", "levels", "user", "verified"}
+
+// scoresCommand looks after the highscores file without starting the game
+func scoresCommand(args []string) error {
+	names := make([]string, 0, len(scoresCommands))
+	for n := range scoresCommands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	usage := "usage: spaceinvaders scores " + strings.Join(names, "|")
+
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	run, ok := scoresCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q, %s", args[0], usage)
+	}
+
+	g := NewGame()
+	g.loadHighscores()
+	return run(g, args[1:])
+}
+
+// sortedHighscores puts the highscores in the order they're listed, board
+// by board
+func (g *Game) sortedHighscores() []*Highscore {
+	scores := append([]*Highscore(nil), g.highscores...)
+	sort.SliceStable(scores, func(i, j int) bool {
+		bi, bj := boardOf(scores[i]), boardOf(scores[j])
+		if bi != bj {
+			return bi.less(bj)
+		}
+		return scores[i].Score > scores[j].Score
+	})
+	return scores
+}
+
+func scoresList(g *Game, args []string) error {
+	fs := flag.NewFlagSet("scores list", flag.ExitOnError)
+	mode := fs.String("mode", "", "only list the `mode` board")
+	fs.Parse(args)
+	if g.highscoresErr != nil {
+		return g.highscoresErr
+	}
+	if *mode != "" && modeByKey(*mode) == NumModes {
+		return fmt.Errorf("unknown mode %q", *mode)
+	}
+
+	var last *Board
+	rank := 0
+	for _, h := range g.sortedHighscores() {
+		if *mode != "" && h.Mode != *mode {
+			continue
+		}
+		if b := boardOf(h); last == nil || b != *last {
+			if last != nil {
+				fmt.Println()
+			}
+			fmt.Println(b)
+			fmt.Println(strings.TrimRight(fmt.Sprintf(highscoreRow, "#", padName("NAME"), "SCORE", "LEVEL", "TIME", "DATE",
+				"SHIP"), " "))
+			last, rank = &b, 0
+		}
+		rank++
+		fmt.Println(strings.TrimRight(highscoreColumns(rank, h), " "))
+	}
+	return nil
+}
+
+func scoresExport(g *Game, args []string) error {
+	fs := flag.NewFlagSet("scores export", flag.ExitOnError)
+	format := fs.String("format", "json", "write `csv` or json")
+	output := fs.String("o", "", "write to `file` instead of standard output")
+	fs.Parse(args)
+	if g.highscoresErr != nil {
+		return g.highscoresErr
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch *format {
+	case "json":
+		data, err := formatHighscores(g.sortedHighscores())
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", data)
+		return err
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write(csvHeader)
+		for _, h := range g.sortedHighscores() {
+			date := ""
+			if !h.Date.IsZero() {
+				date = h.Date.Format(time.RFC3339)
+			}
+			w.Write([]string{h.Name, strconv.Itoa(h.Score), date, strconv.Itoa(h.Level),
+				strconv.Itoa(h.Duration), h.Difficulty, strconv.FormatInt(h.Seed, 10), h.Mode, h.Size, h.Ship,
+				strconv.FormatBool(h.Dives), h.Levels, h.User, h.Verified})
+		}
+		w.Flush()
+		return w.Error()
+	}
+	return fmt.Errorf("unknown format %q, use csv or json", *format)
+}
+
+// scoresImport merges in the highscores from a file in either format
+// loadHighscores reads
+func scoresImport(g *Game, args []string) error {
+	fs := flag.NewFlagSet("scores import", flag.ExitOnError)
+	mode := fs.String("mode", modes[ClassicMode].key, "put highscores from an old hs `file` on this mode's boards")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: spaceinvaders scores import [-mode mode] file")
+	}
+	m := modeByKey(*mode)
+	if m == NumModes {
+		return fmt.Errorf("unknown mode %q", *mode)
+	}
+
+	filename := fs.Arg(0)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	scores, skipped, err := parseHighscores(data, m)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	for _, e := range skipped {
+		fmt.Fprintf(os.Stderr, "%s: skipping %v\n", filename, e)
+	}
+	// anyone can write verified in a file, so only the verify command can
+	// say so again
+	for _, h := range scores {
+		h.Verified = ""
+	}
+
+	had := make(map[string]bool)
+	for _, h := range g.highscores {
+		had[h.key()] = true
+	}
+	g.highscores = append(g.highscores, scores...)
+	if err := g.saveHighscores(); err != nil {
+		return err
+	}
+
+	added := 0
+	for _, h := range g.highscores {
+		if !had[h.key()] {
+			added++
+		}
+	}
+	fmt.Printf("added %d of the %d highscores in %s\n", added, len(scores), filename)
+	return nil
+}
+
+func scoresReset(g *Game, args []string) error {
+	fs := flag.NewFlagSet("scores reset", flag.ExitOnError)
+	yes := fs.Bool("y", false, "don't ask first")
+	fs.Parse(args)
+
+	if !*yes {
+		fmt.Printf("Delete all %d highscores in %s? [y/N] ", len(g.highscores), highscoresPath())
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("Left them alone.")
+			return nil
+		}
+	}
+	if err := g.resetHighscores(); err != nil {
+		return err
+	}
+	fmt.Println("Highscores deleted.")
+	return nil
+}
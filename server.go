@@ -0,0 +1,266 @@
+package main
+
+// The leaderboard server keeps highscores sent in by games that were
+// started with --server. It has one endpoint:
+//
+//	GET /scores?mode=classic&difficulty=normal&size=medium&dives=true
+//
+// returns {"scores": [...]}, the best first, without their replays. size can
+// be left out to get every size, and dives to get games without dive
+// attacks. Games played with a level pack aren't taken.
+//
+//	POST /scores
+//
+// takes a highscore as it's saved in highscores.json and returns
+// {"rank": N}, its place on its board, or 0 if it didn't make it. The
+// highscore's replay has to verify, see verifyHighscore, within
+// verifyBudget. Each client can only send one every submitEvery. Errors are
+// returned as {"error": "..."}.
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	scoresPath        = "/scores"
+	globalFilename    = "global-highscores.json"
+	defaultServerAddr = ":8080"
+
+	// largest highscore that can be sent, replay and all
+	maxRequestSize = 1 << 20
+
+	// longest a replay can take to verify, and how often each client can
+	// send one
+	verifyBudget = 10 * time.Second
+	submitEvery  = 10 * time.Second
+
+	// clients remembered before those that can submit again are forgotten
+	maxClients = 10000
+
+	readTimeout  = 10 * time.Second
+	writeTimeout = verifyBudget + 10*time.Second
+	idleTimeout  = 60 * time.Second
+)
+
+type Server struct {
+	filename    string
+	submitEvery time.Duration
+
+	// mu covers the highscores and when each client last sent one
+	mu          sync.Mutex
+	scores      []*Highscore
+	lastSubmits map[string]time.Time
+
+	// verifying covers the play globals, which verifying uses, so only one
+	// highscore is verified at a time without holding up everything else
+	verifying sync.Mutex
+}
+
+type ScoresResponse struct {
+	Scores []*Highscore `json:"scores"`
+}
+
+type SubmitResponse struct {
+	Rank int `json:"rank"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func newServer(filename string) (*Server, error) {
+	s := &Server{filename: filename, submitEvery: submitEvery, scores: make([]*Highscore, 0),
+		lastSubmits: make(map[string]time.Time)}
+	data, err := ioutil.ReadFile(filename)
+	switch {
+	case os.IsNotExist(err):
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+
+	scores, skipped, err := parseHighscores(data, ClassicMode)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	for _, e := range skipped {
+		log.Printf("%s: skipping %v", filename, e)
+	}
+	s.scores = scores
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != scoresPath {
+		writeJSON(w, http.StatusNotFound, &ErrorResponse{"not found"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.getScores(w, r)
+	case http.MethodPost:
+		s.postScore(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, &ErrorResponse{"method not allowed"})
+	}
+}
+
+func (s *Server) getScores(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	mode, difficulty, size := modeByKey(q.Get("mode")), difficultyByKey(q.Get("difficulty")), sizeByKey(q.Get("size"))
+	dives := q.Get("dives") == "true"
+	switch {
+	case mode == NumModes:
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{"unknown mode"})
+		return
+	case difficulty == NumDifficulties:
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{"unknown difficulty"})
+		return
+	case size == NumSizeClasses && q.Get("size") != "":
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{"unknown size"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scores := make([]*Highscore, 0)
+	for _, h := range trimBoards(s.scores) {
+		b := boardOf(h)
+		if b.mode == mode && b.difficulty == difficulty && (size == NumSizeClasses || b.size == size) && b.dives == dives {
+			c := *h
+			c.Replay = nil
+			scores = append(scores, &c)
+		}
+	}
+	writeJSON(w, http.StatusOK, &ScoresResponse{scores})
+}
+
+func (s *Server) postScore(w http.ResponseWriter, r *http.Request) {
+	var h *Highscore
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&h)
+	if err != nil || h == nil {
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{"highscore isn't valid JSON"})
+		return
+	}
+	if p, ok := h.check(); !ok {
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{highscoreProblems[p]})
+		return
+	}
+	switch {
+	case h.Replay == nil:
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{"highscore has no replay"})
+		return
+	case h.Levels != "" || h.Replay.Levels != "" || h.Replay.Pack != "":
+		writeJSON(w, http.StatusBadRequest, &ErrorResponse{"level packs aren't supported"})
+		return
+	}
+
+	if wait := s.throttle(r); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+		writeJSON(w, http.StatusTooManyRequests, &ErrorResponse{"too many highscores, try again later"})
+		return
+	}
+
+	s.verifying.Lock()
+	err = verifyHighscore(h, time.Now().Add(verifyBudget))
+	s.verifying.Unlock()
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, &ErrorResponse{"replay doesn't match: " + err.Error()})
+		return
+	}
+	h.Verified = verifiedOK
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scores := mergeHighscores(s.scores, []*Highscore{h})
+	data, err := formatHighscores(scores)
+	if err == nil {
+		err = writeFileAtomic(s.filename, data, 0666)
+	}
+	if err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, &ErrorResponse{"couldn't save the highscore"})
+		return
+	}
+	s.scores = scores
+
+	rank := 0
+	for i, o := range scores {
+		if o.key() == h.key() {
+			rank = 1
+			for _, p := range scores[:i] {
+				if boardOf(p) == boardOf(h) {
+					rank++
+				}
+			}
+		}
+	}
+	writeJSON(w, http.StatusCreated, &SubmitResponse{rank})
+}
+
+// throttle notes that the client making r is sending a highscore, and
+// returns how long it has to wait if it sent one too recently
+func (s *Server) throttle(r *http.Request) time.Duration {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if wait := s.lastSubmits[client].Add(s.submitEvery).Sub(now); wait > 0 {
+		return wait
+	}
+	if len(s.lastSubmits) >= maxClients {
+		for c, t := range s.lastSubmits {
+			if now.Sub(t) >= s.submitEvery {
+				delete(s.lastSubmits, c)
+			}
+		}
+	}
+	s.lastSubmits[client] = now
+	return 0
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
+// serveCommand runs the leaderboard server
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", defaultServerAddr, "listen on `address`")
+	filename := fs.String("file", dataPath(globalFilename), "keep the highscores in `file`")
+	fs.Parse(args)
+
+	s, err := newServer(*filename)
+	if err != nil {
+		return err
+	}
+	log.Printf("serving %d highscores from %s on %s", len(s.scores), *filename, *addr)
+	server := &http.Server{
+		Addr:         *addr,
+		Handler:      s,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+	}
+	return server.ListenAndServe()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// playedHighscore plays a game where the player just sits there, so it has
+// a replay that verifies
+func playedHighscore(t *testing.T) *Highscore {
+	h := &Highscore{Name: "ace", Mode: "classic", Difficulty: "normal", Size: "medium", Ship: ships[0].name,
+		Seed: 1, Replay: &Replay{Width: 120, Height: 45, Ticks: maxReplayTicks}}
+	score, end, err := replayGame(h, time.Time{})
+	if err != nil || end < 0 {
+		t.Fatalf("game didn't end: %v", err)
+	}
+	h.Score, h.Replay.Ticks = score, end
+	return h
+}
+
+func TestServer(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), globalFilename)
+	s, err := newServer(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.submitEvery = 0
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	defer func(u string) { serverURL = u }(serverURL)
+	serverURL = ts.URL
+
+	h := playedHighscore(t)
+	data, _ := json.Marshal(h)
+	if rank, err := submitHighscore(data); err != nil || rank != 1 {
+		t.Fatalf("submitting got rank %d, error %v", rank, err)
+	}
+
+	for _, c := range []struct {
+		name string
+		edit func(h *Highscore)
+	}{
+		{"wrong score", func(h *Highscore) { h.Score++ }},
+		{"wrong size", func(h *Highscore) { h.Size = "large" }},
+		{"no replay", func(h *Highscore) { h.Replay = nil }},
+		{"unknown mode", func(h *Highscore) { h.Mode = "nope" }},
+	} {
+		bad := *h
+		r := *h.Replay
+		bad.Replay = &r
+		c.edit(&bad)
+		data, _ := json.Marshal(&bad)
+		if _, err := submitHighscore(data); err == nil {
+			t.Errorf("%s: server took it", c.name)
+		}
+	}
+
+	scores, err := fetchHighscores(boardOf(h))
+	if err != nil || len(scores) != 1 || scores[0].key() != h.key() || scores[0].Replay != nil {
+		t.Fatalf("fetching got %v, error %v", scores, err)
+	}
+	if resp, err := http.Get(ts.URL + scoresPath + "?mode=nope&difficulty=normal"); err != nil ||
+		resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown mode got %v, error %v", resp, err)
+	}
+
+	s, err = newServer(filename)
+	if err != nil || len(s.scores) != 1 || s.scores[0].Verified != verifiedOK {
+		t.Errorf("restarting the server got %v, error %v", s.scores, err)
+	}
+
+	ts.Close()
+	if _, err := fetchHighscores(boardOf(h)); err == nil {
+		t.Errorf("fetching from a server that's gone didn't fail")
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	s, err := newServer(filepath.Join(t.TempDir(), globalFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	defer func(u string) { serverURL = u }(serverURL)
+	serverURL = ts.URL
+
+	h := playedHighscore(t)
+	if err := verifyHighscore(h, time.Now().Add(-time.Second)); err == nil {
+		t.Error("verifying past the deadline didn't fail")
+	}
+
+	data, _ := json.Marshal(h)
+	if _, err := submitHighscore(data); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(scoresURL(), "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("submitting again straight away got %s", resp.Status)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+// A shared highscores file, given with --shared, lets everyone on a machine
+// play for the same boards, like the BSD games did in /var/games. Each
+// highscore there also records the user who set it, looked up from the
+// uid the game runs as. If the file can't be used, the game falls back to
+// the player's own highscores and says why on the Highscores screen.
+//
+// The file is saved the same way as everyone's own highscores, under a
+// lock file and by renaming a new file over it, so players need to be able
+// to write to the directory it's in. The usual set up is a directory and
+// file that belong to a games group, with the game installed setgid games.
+// A file or directory that anyone can write to is refused, as anyone could
+// change the scores.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// new shared files can be written by their group, such as games
+const sharedPerm = 0664
+
+var (
+	// sharedFile is the shared highscores file, if one is being used
+	sharedFile string
+
+	// sharedErr is why the shared file given couldn't be used
+	sharedErr error
+)
+
+// useSharedHighscores checks filename can be used for the shared
+// highscores, creating it if need be, and switches to it
+func useSharedHighscores(filename string) error {
+	dir := filepath.Dir(filename)
+	if err := checkSharedDir(dir); err != nil {
+		return err
+	}
+
+	fi, err := os.Lstat(filename)
+	switch {
+	case os.IsNotExist(err):
+		if err := createShared(filename); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !fi.Mode().IsRegular():
+		return fmt.Errorf("%s isn't a regular file", filename)
+	case fi.Mode().Perm()&0002 != 0:
+		return fmt.Errorf("%s can be written by anyone, it should be %#o", filename, sharedPerm)
+	}
+	sharedFile = filename
+	return nil
+}
+
+// checkSharedDir checks that the shared highscores can be saved in dir, and
+// that not just anyone can replace them
+func checkSharedDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Perm()&0002 != 0 {
+		return fmt.Errorf("%s can be written by anyone", dir)
+	}
+	f, err := ioutil.TempFile(dir, ".spaceinvaders")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// createShared makes an empty shared highscores file that its group can
+// write to, whatever the umask
+func createShared(filename string) error {
+	data, err := formatHighscores(nil)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(filename + lockSuffix)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := os.Stat(filename); err == nil {
+		// another game made it first
+		return nil
+	}
+	return writeFileAtomic(filename, data, sharedPerm)
+}
+
+// highscoresPath is where the highscores are kept
+func highscoresPath() string {
+	if sharedFile != "" {
+		return sharedFile
+	}
+	return dataPath(highscoresFilename)
+}
+
+// highscoresPerm is the permissions the highscores file is saved with
+func highscoresPerm() os.FileMode {
+	if sharedFile != "" {
+		return sharedPerm
+	}
+	return 0666
+}
+
+// currentUser is the login of whoever's playing, recorded on the shared
+// highscores. It's looked up from the real uid rather than $USER, which
+// players could set to anything.
+func currentUser() string {
+	if sharedFile == "" {
+		return ""
+	}
+	uid := os.Getuid()
+	if uid < 0 {
+		// there are no uids on Windows
+		if u, err := user.Current(); err == nil {
+			return u.Username
+		}
+		return ""
+	}
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		return u.Username
+	}
+	return strconv.Itoa(uid)
+}
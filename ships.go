@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+)
+
+type Ship struct {
+	name   string
+	blurb  string
+	sprite string
+	// non-space cells are the ones that can be hit
+	hitbox string
+	width  int
+	fg     termbox.Attribute
+
+	speed int
+	// bullets fired at once, and how many can be in flight
+	shots      int
+	maxBullets int
+	// hits soaked up each level
+	shield int
+	// added to the mode's lives, but never below one
+	extraLives int
+}
+
+const (
+	fgShips        = neonGreen
+	bgShips        = termbox.ColorBlack
+	fgShipShielded = cyan
+	shipsTitle     = "SELECT SHIP"
+	shipsColumn    = 18
+	shipsWPad      = 6
+	shipsHeight    = 14
+	shieldText     = "Shield "
+)
+
+var ships = []*Ship{
+	&Ship{"CLASSIC", "all-rounder", playerSprite, playerSprite, playerSpriteWidth, fgPlayer,
+		playerMoveSpeed, 1, 1, 0, 0},
+	&Ship{"DART", "fast, fragile", dartSprite, dartHitbox, dartSpriteWidth, yellow,
+		3, 1, 1, 0, -2},
+	&Ship{"HAMMER", "slow, double shot", hammerSprite, hammerSprite, playerSpriteWidth, orange,
+		1, 2, 2, 0, 0},
+	&Ship{"BULWARK", "wide, shielded", wideSprite, wideSprite, wideSpriteWidth, cyan,
+		playerMoveSpeed, 1, 1, 1, 0},
+}
+
+func (s *Ship) lives(modeLives int) int {
+	if modeLives == 0 {
+		return 0
+	}
+	if l := modeLives + s.extraLives; l > 0 {
+		return l
+	}
+	return 1
+}
+
+func (s *Ship) stats() []string {
+	lines := []string{fmt.Sprintf("speed %d", s.speed), fmt.Sprintf("%d shot", s.shots)}
+	if s.shots > 1 {
+		lines[1] += "s"
+	}
+	if s.shield > 0 {
+		lines = append(lines, fmt.Sprintf("%d hit shield", s.shield))
+	}
+	if s.extraLives != 0 {
+		lines = append(lines, fmt.Sprintf("%+d lives", s.extraLives))
+	}
+	return lines
+}
+
+func NewPlayer(x, y int, s *Ship, lives int) *Player {
+	return &Player{RegEntity{Entity{x, y, s.fg, bgPlayer}, s.sprite}, 0, lives,
+		make([]*Bullet, s.maxBullets), s, s.shield}
+}
+
+// playerFire shoots, if enough bullets are free
+func playerFire() {
+	free := make([]int, 0)
+	for i, b := range player.bullets {
+		if b == nil {
+			free = append(free, i)
+		}
+	}
+	if len(free) < player.ship.shots {
+		return
+	}
+
+	tally.shots += player.ship.shots
+	if player.ship.shots == 1 {
+		player.bullets[free[0]] = NewBullet(player.x+player.ship.width/2, player.y, playerBulletSpeed)
+		return
+	}
+	for i := 0; i < player.ship.shots; i++ {
+		x := player.x + 1 + i*(player.ship.width-3)/(player.ship.shots-1)
+		player.bullets[free[i]] = NewBullet(x, player.y, playerBulletSpeed)
+	}
+}
+
+// absorbHit uses up some of the shield, if there's any left
+func (g *Game) absorbHit() bool {
+	if g.invulnerable() {
+		return true
+	}
+	if player.shield > 0 {
+		player.shield--
+		return true
+	}
+	return false
+}
+
+func (g *Game) DrawShips() {
+	g.DrawMenu()
+
+	w := len(ships)*shipsColumn + shipsWPad
+	x, y := g.w/2-w/2, logoY
+	tbrect(x, y, w, shipsHeight, fgShips, bgShips, true)
+
+	y++
+	tbprint(g.w/2-len(shipsTitle)/2, y, fgShips, bgShips, shipsTitle)
+	y += 2
+
+	x += shipsWPad / 2
+	for i, s := range ships {
+		cx := x + i*shipsColumn + shipsColumn/2
+		tbprintsprite(cx-s.width/2, y, s.fg, bgShips, s.sprite)
+
+		name := " " + s.name + " "
+		if i == g.ship {
+			tbprint(cx-len(name)/2, y+playerSpriteHeight+1, fgModeHighlight, bgModeHighlight, name)
+		} else {
+			tbprint(cx-len(name)/2, y+playerSpriteHeight+1, fgShips, bgShips, name)
+		}
+		tbprint(cx-len(s.blurb)/2, y+playerSpriteHeight+2, fgShips, bgShips, s.blurb)
+		for j, l := range s.stats() {
+			tbprint(cx-len(l)/2, y+playerSpriteHeight+3+j, fgShips, bgShips, l)
+		}
+	}
+
+	y += shipsHeight - 4
+	tbprintPrompt(y, "Press ", "Enter ", "to play, ", "ESC ", "to go back")
+
+	tbprint(g.w/2-len(g.startMsg)/2, logoY+shipsHeight+1, fgHighscoresErr, bgMenu, g.startMsg)
+}
+
+func (g *Game) UpdateShips() {
+	g.UpdateMenu()
+}
+
+func (g *Game) HandleKeyShips(k termbox.Key) {
+	switch k {
+	case termbox.KeyArrowLeft:
+		g.ship = (g.ship - 1 + len(ships)) % len(ships)
+	case termbox.KeyArrowRight:
+		g.ship = (g.ship + 1) % len(ships)
+	case termbox.KeyEsc:
+		g.GoModes()
+	case termbox.KeyEnter:
+		fallthrough
+	case termbox.KeySpace:
+		g.startPlay()
+	}
+}
+
+func (g *Game) GoShips() {
+	g.startMsg = ""
+	g.state = ShipsState
+	g.cfg = fgMenu
+	g.cbg = bgMenu
+}
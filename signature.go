@@ -0,0 +1,136 @@
+package main
+
+// The highscores file is signed with an HMAC, using a key made the first
+// time the game runs and kept in the config directory, so scores edited by
+// hand can be spotted. It doesn't stop anyone who has the key, but it does
+// mean the Highscores screen can say when the file has been changed by
+// something other than the game. Once it has been, the game stops signing
+// it, so the changes can't be passed off as genuine by saving over them,
+// until the highscores are reset. Shared files aren't signed, as every
+// player has their own key.
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	keyFilename = "key"
+	keySize     = 32
+)
+
+// loadKey reads the key the highscores are signed with, making one if
+// there isn't one yet. created is set if it was just made, when there's
+// nothing to check the highscores against.
+func loadKey() (key []byte, created bool, err error) {
+	filename := configPath(keyFilename)
+	data, err := ioutil.ReadFile(filename)
+	if err == nil {
+		key, err = hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != keySize {
+			return nil, false, fmt.Errorf("%s isn't a valid key", filename)
+		}
+		return key, false, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(dirs.config, dataDirPerm); err != nil {
+		return nil, false, err
+	}
+	if err := writeFileAtomic(filename, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
+
+// signHighscores returns the signature of the scores in a highscores file,
+// which is worked out from every byte of them apart from the whitespace,
+// so it doesn't matter how they're laid out but entries that wouldn't load
+// still count
+func signHighscores(key []byte, raw []byte) (string, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(compact.Bytes())
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// signedParts finds the scores in a highscores file, as they were written,
+// and its signature, if it has one
+func signedParts(data []byte) (raw json.RawMessage, sig string) {
+	var f struct {
+		Scores    json.RawMessage `json:"scores"`
+		Signature string          `json:"signature"`
+	}
+	if json.Unmarshal(data, &f) != nil {
+		return nil, ""
+	}
+	return f.Scores, f.Signature
+}
+
+// signing reports whether the highscores file is signed. Once it's been
+// modified it isn't, so what was changed isn't passed off as genuine.
+func (g *Game) signing() bool {
+	return g.highscoresKey != nil && sharedFile == "" && !g.highscoresModified
+}
+
+// formatSignedHighscores is formatHighscores with the signature added
+func (g *Game) formatSignedHighscores(scores []*Highscore) ([]byte, error) {
+	if !g.signing() {
+		return formatHighscores(scores)
+	}
+	if scores == nil {
+		scores = make([]*Highscore, 0)
+	}
+	raw, err := json.Marshal(scores)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signHighscores(g.highscoresKey, raw)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&HighscoreFile{highscoresVersion, scores, sig}, "", "  ")
+}
+
+// checkSignature notes if data wasn't what the game last saved. A file from
+// before there were signatures is only suspicious if there was already a
+// key. The file stays unsigned from then on, so the warning doesn't go away
+// until the highscores are reset.
+func (g *Game) checkSignature(filename string, data []byte) {
+	if !g.signing() {
+		return
+	}
+	raw, sig := signedParts(data)
+	if sig == "" && g.keyCreated {
+		return
+	}
+	want := ""
+	if raw != nil {
+		var err error
+		if want, err = signHighscores(g.highscoresKey, raw); err != nil {
+			log.Println(err)
+		}
+	}
+	if want == "" || !hmac.Equal([]byte(sig), []byte(want)) {
+		log.Printf("%s: signature doesn't match, the highscores were modified externally", filename)
+		g.highscoresModified = true
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSignature(t *testing.T) {
+	data := []byte(`{"version":1,"scores":[{"name":"ace","score":10,"date":"2026-10-19T18:04:05.123+02:00",` +
+		`"difficulty":"hard","mode":"classic","replay":{"width":120,"height":45,"ticks":9,"inputs":"0L0R3F"}},` +
+		`{"name":"漢字","score":5,"mode":"classic","difficulty":"easy","verified":"ok"}]}`)
+	scores, _, err := parseHighscores(data, ClassicMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGame()
+	g.highscoresKey = bytes.Repeat([]byte{1}, keySize)
+	signed, err := g.formatSignedHighscores(scores)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name     string
+		data     []byte
+		modified bool
+	}{
+		{"signed", signed, false},
+		{"reformatted", bytes.Replace(signed, []byte("\n    "), []byte(" "), -1), false},
+		{"score changed", bytes.Replace(signed, []byte(`"score": 10`), []byte(`"score": 99`), 1), true},
+		{"entry removed", bytes.Replace(signed, []byte(`"name": "漢字"`), []byte(`"name": ""`), 1), true},
+		{"skipped entry added", bytes.Replace(signed, []byte(`"scores": [`), []byte(`"scores": [null,`), 1), true},
+		{"unsigned", data, true},
+	} {
+		g.highscoresModified = false
+		g.checkSignature(c.name, c.data)
+		if g.highscoresModified != c.modified {
+			t.Errorf("%s: modified is %v, want %v", c.name, g.highscoresModified, c.modified)
+		}
+	}
+
+	// there's nothing to check against until there's been a key
+	g.highscoresModified, g.keyCreated = false, true
+	g.checkSignature("unsigned", data)
+	if g.highscoresModified {
+		t.Error("unsigned file with a new key counted as modified")
+	}
+}
+
+// TestSignatureSticks checks that saving over a modified file doesn't sign
+// the changes
+func TestSignatureSticks(t *testing.T) {
+	defer func(d Dirs) { dirs = d }(dirs)
+	dir := t.TempDir()
+	dirs = Dirs{dir, dir, dir}
+
+	load := func() *Game {
+		g := NewGame()
+		g.loadHighscores()
+		if g.highscoresErr != nil {
+			t.Fatal(g.highscoresErr)
+		}
+		return g
+	}
+
+	g := load()
+	g.highscores = append(g.highscores, &Highscore{Name: "ace", Score: 10, Mode: "classic", Difficulty: "normal",
+		Size: "medium"})
+	if err := g.saveHighscores(); err != nil {
+		t.Fatal(err)
+	}
+	if g = load(); g.highscoresModified {
+		t.Fatal("saved highscores counted as modified")
+	}
+
+	filename := dataPath(highscoresFilename)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(`"score": 10`), []byte(`"score": 9999`), 1)
+	if err := ioutil.WriteFile(filename, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	g = load()
+	if !g.highscoresModified {
+		t.Fatal("modified highscores weren't noticed")
+	}
+	g.highscores = append(g.highscores, &Highscore{Name: "bob", Score: 5, Mode: "classic", Difficulty: "normal",
+		Size: "medium"})
+	if err := g.saveHighscores(); err != nil {
+		t.Fatal(err)
+	}
+	if g = load(); !g.highscoresModified {
+		t.Fatal("saving over modified highscores signed them")
+	}
+
+	if err := g.resetHighscores(); err != nil {
+		t.Fatal(err)
+	}
+	if g = load(); g.highscoresModified {
+		t.Error("reset highscores counted as modified")
+	}
+}
@@ -16,6 +16,20 @@ OXOOXO`
 	bgPlayer           = termbox.ColorBlack
 	livesSprite        = `⏣ `
 
+	dartSpriteWidth = 4
+	wideSpriteWidth = 10
+	dartSprite      = ` /\
+/oo\
+ ^^`
+	dartHitbox = ` /\
+ oo`
+	hammerSprite = `|/\/\|
+HHxxHH
+H'  'H`
+	wideSprite = `   _/\_
+[OOxxxxOO]
+[XOOXXOOX]`
+
 	alienSpriteWidth  = 8
 	alienSpriteHeight = 4
 
@@ -60,6 +74,42 @@ x xxxx x
  xxxxxx
   \||/`}
 
+	armouredAlienSprite = [2]string{` [xxxx]
+ x#OO#x
+ xxxxxx
+  |/\|`, ` [xxxx]
+ x#OO#x
+ xxxxxx
+  /||\`}
+
+	splitterAlienSprite = [2]string{`  x  x
+ xOx xOx
+  xx xx
+  /\ /\`, `  x  x
+ xOx xOx
+  xx xx
+  \/ \/`}
+
+	tinyAlienSprite = [2]string{`  oo
+ /\/\`, `  oo
+ \/\/`}
+
+	sniperAlienSprite = [2]string{`   ||
+  xOOx
+ x-xx-x
+  /  \`, `   ||
+  xOOx
+ x-xx-x
+  \  /`}
+
+	shielderAlienSprite = [2]string{` (xxxx)
+(xOxxOx)
+ (xxxx)
+  /  \`, ` (xxxx)
+(xOxxOx)
+ (xxxx)
+  \  /`}
+
 	ufoSprite = `  xxxxx
 xxoxOxoxx
  ##   ##`
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// LifetimeStats are kept over every game ever played
+type LifetimeStats struct {
+	Games        int            `json:"games"`
+	PlaySeconds  int            `json:"play_seconds"`
+	Shots        int            `json:"shots"`
+	Hits         int            `json:"hits"`
+	Kills        map[string]int `json:"kills"`
+	UFOs         int            `json:"ufos"`
+	Deaths       map[string]int `json:"deaths"`
+	HighestLevel int            `json:"highest_level"`
+
+	// most recent last
+	RecentScores []int `json:"recent_scores"`
+}
+
+// causes of death
+const (
+	deathShot    = "shot"
+	deathRammed  = "rammed"
+	deathInvaded = "invaded"
+)
+
+const (
+	statsFilename   = "stats"
+	statsTitle      = "STATS"
+	fgStats         = neonGreen
+	bgStats         = termbox.ColorBlack
+	fgSparkline     = yellow
+	statsWidth      = 72
+	statsLabelPad   = 18
+	statsKillsPad   = 16
+	maxRecentScores = 40
+	sparks          = "▁▂▃▄▅▆▇█"
+)
+
+var deathCauses = []string{deathShot, deathRammed, deathInvaded}
+
+func newStats() *LifetimeStats {
+	return &LifetimeStats{Kills: make(map[string]int), Deaths: make(map[string]int)}
+}
+
+func (g *Game) loadStats() {
+	data, err := ioutil.ReadFile(dataPath(statsFilename))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println(err)
+		}
+		return
+	}
+
+	s := newStats()
+	if err := json.Unmarshal(data, s); err != nil {
+		log.Println("stats file has been corrupted - please correct/delete it:", err)
+		return
+	}
+	if s.Kills == nil {
+		s.Kills = make(map[string]int)
+	}
+	if s.Deaths == nil {
+		s.Deaths = make(map[string]int)
+	}
+	g.stats = s
+}
+
+func (g *Game) saveStats() {
+	data, err := json.MarshalIndent(g.stats, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(dataPath(statsFilename), data, 0666)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// recordStats adds the game that has just ended to the lifetime stats
+func (g *Game) recordStats() {
+	if !g.onRecord() {
+		return
+	}
+
+	s := g.stats
+	s.Games++
+	s.PlaySeconds += tally.frames / fps
+	s.Shots += tally.shots
+	s.Hits += tally.hits
+	for c, n := range tally.kills {
+		s.Kills[alienKind(c).name] += n
+	}
+	s.UFOs += tally.ufoHits
+	for cause, n := range tally.deaths {
+		s.Deaths[cause] += n
+	}
+	if lvl > s.HighestLevel {
+		s.HighestLevel = lvl
+	}
+	s.RecentScores = append(s.RecentScores, player.score)
+	if len(s.RecentScores) > maxRecentScores {
+		s.RecentScores = s.RecentScores[len(s.RecentScores)-maxRecentScores:]
+	}
+
+	g.saveStats()
+}
+
+func (s *LifetimeStats) accuracy() string {
+	if s.Shots == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d%%", s.Hits*100/s.Shots)
+}
+
+// sparkline draws each score as a bar, scaled to the best of them
+func sparkline(scores []int) string {
+	bars := []rune(sparks)
+	top := 0
+	for _, s := range scores {
+		if s > top {
+			top = s
+		}
+	}
+
+	line := make([]rune, len(scores))
+	for i, s := range scores {
+		line[i] = bars[0]
+		if top > 0 {
+			line[i] = bars[s*(len(bars)-1)/top]
+		}
+	}
+	return string(line)
+}
+
+func (g *Game) DrawStats() {
+	g.DrawMenu()
+
+	s := g.stats
+	rows := (len(alienKinds) + 2) / 3
+	w, h := statsWidth, 15+rows
+	x, y := g.w/2-w/2, logoY
+	tbrect(x, y, w, h, fgStats, bgStats, true)
+
+	y++
+	tbprint(g.w/2-len(statsTitle)/2, y, fgStats, bgStats, statsTitle)
+	y += 2
+
+	x += 4
+	deaths := make([]string, len(deathCauses))
+	for i, cause := range deathCauses {
+		deaths[i] = fmt.Sprintf("%s %d", cause, s.Deaths[cause])
+	}
+	for _, l := range [][2]string{
+		{"Games played", fmt.Sprintf("%d", s.Games)},
+		{"Play time", (time.Duration(s.PlaySeconds) * time.Second).String()},
+		{"Shots fired", fmt.Sprintf("%d", s.Shots)},
+		{"Accuracy", s.accuracy()},
+		{"UFOs hit", fmt.Sprintf("%d", s.UFOs)},
+		{"Highest level", fmt.Sprintf("%d", s.HighestLevel)},
+		{"Deaths", strings.Join(deaths, ", ")},
+	} {
+		tbprint(x, y, fgStats, bgStats, fmt.Sprintf("%-*s%s", statsLabelPad, l[0], l[1]))
+		y++
+	}
+
+	y++
+	tbprint(x, y, fgStats, bgStats, "Kills")
+	for i, k := range alienKinds {
+		kx := x + statsLabelPad + (i%3)*statsKillsPad
+		tbprint(kx, y+i/3, fgStats, bgStats, fmt.Sprintf("%s %d", k.name, s.Kills[k.name]))
+	}
+	y += rows + 1
+
+	tbprint(x, y, fgStats, bgStats, "Recent scores")
+	if len(s.RecentScores) == 0 {
+		tbprint(x+statsLabelPad, y, fgStats, bgStats, "-")
+	} else {
+		tbprint(x+statsLabelPad, y, fgSparkline, bgStats, sparkline(s.RecentScores))
+	}
+	y += 2
+
+	tbprintPrompt(y, "Press ", "ESC ", "to exit")
+}
+
+func (g *Game) UpdateStats() {
+	g.UpdateMenu()
+}
+
+func (g *Game) HandleKeyStats(k termbox.Key) {
+	switch k {
+	case termbox.KeyEsc:
+		g.GoMenu()
+		g.hmi = Stats
+	}
+}
+
+func (g *Game) GoStats() {
+	g.state = StatsState
+	g.cfg = fgMenu
+	g.cbg = bgMenu
+}